@@ -0,0 +1,206 @@
+package commandcert
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func AcmeCommand(cmd *cobra.Command, args []string) {
+	yes, _ := cmd.Flags().GetBool("yes")
+	noUpgrade, _ := cmd.Flags().GetBool("no-upgrade")
+	force, _ := cmd.Flags().GetBool("force")
+
+	chartVersion := utils.DeployedChartVersion()
+	if !noUpgrade {
+		if err := utils.CheckBeforeUpgrade(chartVersion, force); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if disable, _ := cmd.Flags().GetBool("disable"); disable {
+		if err := utils.DisableACME(); err != nil {
+			pterm.Error.Printfln("Error updating configuration: %v", err)
+			os.Exit(1)
+		}
+		pterm.Info.Println("Provide a certificate with 'netsocs cert generate' or 'netsocs cert import'")
+		upgrade(chartVersion, noUpgrade)
+		return
+	}
+
+	host := utils.ConfiguredHost()
+	if host == "" {
+		pterm.Error.Println("httpHostname is not configured; run 'netsocs config' first")
+		os.Exit(1)
+	}
+
+	config, caFile := acmeConfigFromFlags(cmd, host)
+
+	if skip, _ := cmd.Flags().GetBool("skip-check"); !skip {
+		checkACME(config, caFile, host)
+	}
+
+	if !yes && utils.StdinIsTerminal() {
+		ok := false
+		message := fmt.Sprintf("Request certificates for %s from %s with the %s-01 challenge?", host, config.Server, config.Challenge)
+		if err := survey.AskOne(&survey.Confirm{Message: message, Default: true}, &ok); err != nil || !ok {
+			pterm.Warning.Println("Configuration cancelled")
+			return
+		}
+	}
+
+	if err := utils.EnableACME(config); err != nil {
+		pterm.Error.Printfln("Error updating configuration: %v", err)
+		os.Exit(1)
+	}
+	upgrade(chartVersion, noUpgrade)
+	pterm.Info.Println("Traefik requests the certificate when it starts; check it with 'netsocs cert show' in a few minutes")
+}
+
+// acmeConfigFromFlags builds the configuration from the flags, prompting for
+// what is missing when running in a terminal.
+func acmeConfigFromFlags(cmd *cobra.Command, host string) (utils.ACMEConfig, string) {
+	config := utils.ACMEConfig{}
+	config.Email, _ = cmd.Flags().GetString("email")
+	config.Server, _ = cmd.Flags().GetString("server")
+	config.Challenge, _ = cmd.Flags().GetString("challenge")
+	config.Provider, _ = cmd.Flags().GetString("dns-provider")
+	caFile, _ := cmd.Flags().GetString("ca-file")
+	staging, _ := cmd.Flags().GetBool("staging")
+
+	switch {
+	case config.Server != "" && staging:
+		pterm.Error.Println("--server and --staging cannot be used together")
+		os.Exit(1)
+	case staging:
+		config.Server = utils.LetsEncryptStagingServer
+	case config.Server == "":
+		config.Server = utils.LetsEncryptServer
+	}
+	// A private server such as Pebble validates whatever it can reach.
+	if config.Server == utils.LetsEncryptServer || config.Server == utils.LetsEncryptStagingServer {
+		if err := utils.CheckACMEDomain(host); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if config.Email == "" && utils.StdinIsTerminal() {
+		if err := survey.AskOne(&survey.Input{Message: "Email for expiry notices from the CA (optional):"}, &config.Email); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err == nil {
+			_, err = utils.ParsePEMCertificates(data)
+		}
+		if err != nil {
+			pterm.Error.Printfln("--ca-file %s: %v", caFile, err)
+			os.Exit(1)
+		}
+		config.CACertificate = string(data)
+	}
+
+	switch config.Challenge {
+	case utils.ACMEChallengeHTTP:
+		if config.Provider != "" {
+			pterm.Error.Println("--dns-provider requires --challenge dns")
+			os.Exit(1)
+		}
+	case utils.ACMEChallengeDNS:
+		config.Credentials = dnsCredentials(&config)
+	default:
+		pterm.Error.Printfln("Unknown challenge %q, use http or dns", config.Challenge)
+		os.Exit(1)
+	}
+	return config, caFile
+}
+
+// dnsCredentials reads the credentials of the DNS provider from the
+// environment variables Traefik uses, prompting for missing ones.
+func dnsCredentials(config *utils.ACMEConfig) map[string]string {
+	if config.Provider == "" && utils.StdinIsTerminal() {
+		var names []string
+		for _, provider := range utils.DNSProviders {
+			names = append(names, provider.Name)
+		}
+		if err := survey.AskOne(&survey.Select{Message: "DNS provider:", Options: names}, &config.Provider); err != nil {
+			os.Exit(1)
+		}
+	}
+	provider, err := utils.FindDNSProvider(config.Provider)
+	if err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	credentials := map[string]string{}
+	for _, name := range provider.Env {
+		value := os.Getenv(name)
+		if value == "" && utils.StdinIsTerminal() {
+			if err := survey.AskOne(&survey.Password{Message: name + ":"}, &value); err != nil {
+				os.Exit(1)
+			}
+		}
+		if value == "" {
+			pterm.Error.Printfln("%s is required for %s; set the environment variable", name, provider.Name)
+			os.Exit(1)
+		}
+		credentials[name] = value
+	}
+	return credentials
+}
+
+// checkACME verifies the ACME server and the challenge before anything is
+// changed, so a broken setup does not leave the site without a certificate.
+func checkACME(config utils.ACMEConfig, caFile, host string) {
+	spinner, _ := pterm.DefaultSpinner.Start("Checking the ACME server...")
+	if err := utils.CheckACMEDirectory(config.Server, caFile); err != nil {
+		spinner.Fail(err.Error())
+		os.Exit(1)
+	}
+	spinner.Success(fmt.Sprintf("ACME server %s is reachable", config.Server))
+
+	switch config.Challenge {
+	case utils.ACMEChallengeHTTP:
+		if warning := utils.CheckHostPointsHere(host); warning != "" {
+			pterm.Warning.Println(warning)
+		}
+		spinner, _ = pterm.DefaultSpinner.Start("Checking the HTTP-01 challenge path...")
+		warning, err := utils.CheckHTTPChallengePath(host)
+		if err != nil {
+			spinner.Fail(err.Error())
+			pterm.Info.Println("Open port 80 to the internet, or use --challenge dns")
+			os.Exit(1)
+		}
+		if warning != "" {
+			spinner.Warning(warning)
+		} else {
+			spinner.Success(fmt.Sprintf("http://%s/.well-known/acme-challenge/ answers from this server", host))
+		}
+		pterm.Info.Println("This only checks port 80 from this server; the ACME server must also reach it from the internet")
+	case utils.ACMEChallengeDNS:
+		provider, _ := utils.FindDNSProvider(config.Provider)
+		if warning := utils.CheckDNSProviderZone(host, provider); warning != "" {
+			pterm.Warning.Println(warning)
+		}
+	}
+}
+
+func upgrade(chartVersion string, noUpgrade bool) {
+	if noUpgrade {
+		pterm.Info.Println("Skipping the Helm upgrade (--no-upgrade); run 'netsocs upgrade' to apply")
+		return
+	}
+	if err := utils.RunHelmUpgradeWithVersion(chartVersion); err != nil {
+		pterm.Error.Printfln("Error running Helm: %v", err)
+		os.Exit(1)
+	}
+}
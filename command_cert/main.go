@@ -0,0 +1,334 @@
+package commandcert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func GenerateCommand(cmd *cobra.Command, args []string) {
+	hosts, _ := cmd.Flags().GetStringSlice("host")
+	if len(hosts) == 0 {
+		host := utils.ConfiguredHost()
+		if host == "" {
+			pterm.Error.Println("httpHostname is not configured; run 'netsocs config' or pass --host")
+			os.Exit(1)
+		}
+		hosts = []string{host}
+	}
+	for i, host := range hosts {
+		address, err := utils.ParseAddress(host)
+		if err != nil {
+			pterm.Error.Printfln("Invalid host %q: %v", host, err)
+			os.Exit(1)
+		}
+		hosts[i] = address.Host
+	}
+
+	if !confirmReplace(cmd) {
+		return
+	}
+
+	ca, created, err := utils.IssueLocalCertificate(hosts)
+	if err != nil {
+		pterm.Error.Printfln("Error issuing the certificate: %v", err)
+		os.Exit(1)
+	}
+	if created {
+		pterm.Success.Printfln("Created a local CA for %s in %s", strings.Join(hosts, ", "), ca.CertPath)
+	}
+	pterm.Success.Printfln("Issued a certificate for %s into secret %s", strings.Join(hosts, ", "), utils.TLSSecretName())
+	pterm.Info.Printfln("Import %s as a trusted root on the client machines to avoid browser warnings", ca.CertPath)
+}
+
+func ImportCommand(cmd *cobra.Command, args []string) {
+	certPath, _ := cmd.Flags().GetString("cert")
+	keyPath, _ := cmd.Flags().GetString("key")
+	pfxPath, _ := cmd.Flags().GetString("pfx")
+
+	var imported *utils.ImportedCertificate
+	var err error
+	switch {
+	case pfxPath != "" && certPath == "":
+		password, perr := pfxPassword(cmd)
+		if perr != nil {
+			pterm.Error.Println(perr)
+			os.Exit(1)
+		}
+		imported, err = utils.LoadPFXCertificate(pfxPath, password)
+	case certPath != "" && pfxPath == "":
+		imported, err = utils.LoadPEMCertificate(certPath, keyPath)
+	default:
+		pterm.Error.Println("Pass either --cert (and --key) with PEM files or --pfx with a PKCS#12 file")
+		os.Exit(1)
+	}
+	if err != nil {
+		pterm.Error.Printfln("Error reading the certificate: %v", err)
+		os.Exit(1)
+	}
+
+	printChain(imported.Chain)
+	for _, warning := range imported.Warnings(utils.ConfiguredHost()) {
+		pterm.Warning.Println(warning)
+	}
+
+	if !confirmReplace(cmd) {
+		return
+	}
+	if err := imported.Import(); err != nil {
+		pterm.Error.Printfln("Error importing the certificate: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Certificate imported into secret %s", utils.TLSSecretName())
+}
+
+// pfxPassword reads the PFX password from --password-file,
+// NETSOCS_PFX_PASSWORD or a prompt, in that order.
+func pfxPassword(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("password-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if password, ok := os.LookupEnv("NETSOCS_PFX_PASSWORD"); ok {
+		return password, nil
+	}
+	if !utils.StdinIsTerminal() {
+		return "", nil
+	}
+	var password string
+	err := survey.AskOne(&survey.Password{Message: "PFX password (empty if none):"}, &password)
+	return password, err
+}
+
+// confirmReplace asks before overwriting the certificate currently in use.
+func confirmReplace(cmd *cobra.Command) bool {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes || !utils.StdinIsTerminal() {
+		return true
+	}
+	secret, err := utils.GetTLSSecret()
+	if err != nil {
+		return true
+	}
+	ok := false
+	message := fmt.Sprintf("Replace the current certificate of secret %s (%s, expires %s)?",
+		secret.Name, sourceLabel(secret.Source), secret.Chain[0].NotAfter.Format("2006-01-02"))
+	if err := survey.AskOne(&survey.Confirm{Message: message, Default: true}, &ok); err != nil || !ok {
+		pterm.Warning.Println("Certificate left unchanged")
+		return false
+	}
+	return true
+}
+
+type certInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Hosts     []string  `json:"hosts,omitempty"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	DaysLeft  int       `json:"daysLeft"`
+	IsCA      bool      `json:"isCA"`
+}
+
+type certReport struct {
+	Secret  string     `json:"secret,omitempty"`
+	Source  string     `json:"source"`
+	Host    string     `json:"host,omitempty"`
+	Covers  bool       `json:"coversHost"`
+	Chain   []certInfo `json:"chain"`
+	LocalCA *certInfo  `json:"localCA,omitempty"`
+}
+
+func ShowCommand(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+
+	if utils.ACMEEnabled() {
+		showServed(output)
+		return
+	}
+
+	secret, err := utils.GetTLSSecret()
+	if errors.Is(err, utils.ErrTLSSecretNotFound) {
+		pterm.Warning.Printfln("Secret %s does not exist. Create it with 'netsocs cert generate' or 'netsocs cert import'", utils.TLSSecretName())
+		os.Exit(1)
+	}
+	if err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	report := certReport{Secret: secret.Name, Source: sourceLabel(secret.Source), Host: utils.ConfiguredHost()}
+	report.Covers = report.Host == "" || utils.CertificateCovers(secret.Chain[0], report.Host)
+	for _, cert := range secret.Chain {
+		report.Chain = append(report.Chain, newCertInfo(cert))
+	}
+	if ca, err := utils.LoadLocalCA(); err == nil && ca != nil {
+		info := newCertInfo(ca.Cert)
+		report.LocalCA = &info
+	}
+
+	if output == "json" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	pterm.DefaultSection.Printfln("Secret %s (%s)", report.Secret, report.Source)
+	printChain(secret.Chain)
+	if !report.Covers {
+		pterm.Warning.Printfln("The certificate does not cover the configured address %s", report.Host)
+	}
+	if report.LocalCA != nil {
+		pterm.Info.Printfln("Local CA: %s, expires %s", report.LocalCA.Subject, report.LocalCA.NotAfter.Format("2006-01-02"))
+	}
+}
+
+// showServed reports the certificate presented at the configured address,
+// since ACME certificates live in Traefik and not in the TLS secret.
+func showServed(output string) {
+	address := utils.ConfiguredAddress()
+	host := utils.ConfiguredHost()
+	chain, err := utils.ServedCertificate(address)
+	if err != nil {
+		pterm.Error.Printfln("Error reading the certificate served at %s: %v", address, err)
+		os.Exit(1)
+	}
+
+	report := certReport{Source: sourceLabel(utils.CertSourceACME), Host: host, Covers: utils.CertificateCovers(chain[0], host)}
+	for _, cert := range chain {
+		report.Chain = append(report.Chain, newCertInfo(cert))
+	}
+	if output == "json" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	pterm.DefaultSection.Printfln("Served at %s (%s)", address, report.Source)
+	printChain(chain)
+	if !report.Covers {
+		pterm.Warning.Printfln("The served certificate does not cover %s; the ACME resolver may still be requesting it, check the Traefik logs", host)
+	}
+}
+
+func newCertInfo(cert *x509.Certificate) certInfo {
+	return certInfo{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		Hosts:     utils.CertificateHosts(cert),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		DaysLeft:  utils.DaysUntil(cert.NotAfter),
+		IsCA:      cert.IsCA,
+	}
+}
+
+func printChain(chain []*x509.Certificate) {
+	tableData := pterm.TableData{{"#", "Subject", "Issuer", "Hosts", "Expires", "Days left"}}
+	for i, cert := range chain {
+		info := newCertInfo(cert)
+		days := fmt.Sprintf("%d", info.DaysLeft)
+		switch {
+		case info.DaysLeft < 7:
+			days = pterm.Red(days)
+		case info.DaysLeft < 30:
+			days = pterm.Yellow(days)
+		}
+		tableData = append(tableData, []string{
+			fmt.Sprintf("%d", i),
+			info.Subject,
+			info.Issuer,
+			strings.Join(info.Hosts, ", "),
+			info.NotAfter.Format("2006-01-02"),
+			days,
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+func sourceLabel(source string) string {
+	switch source {
+	case utils.CertSourceLocalCA:
+		return "issued by the local CA"
+	case utils.CertSourceImported:
+		return "imported"
+	case utils.CertSourceACME:
+		return "ACME"
+	default:
+		return "unknown origin"
+	}
+}
+
+func RenewCommand(cmd *cobra.Command, args []string) {
+	days, _ := cmd.Flags().GetInt("days")
+	force, _ := cmd.Flags().GetBool("force")
+	host := utils.ConfiguredHost()
+
+	if utils.ACMEEnabled() {
+		pterm.Info.Println("Certificates are renewed automatically by the ACME resolver; see 'netsocs cert show'")
+		return
+	}
+
+	secret, err := utils.GetTLSSecret()
+	switch {
+	case errors.Is(err, utils.ErrTLSSecretNotFound):
+		if host == "" {
+			pterm.Error.Println("No certificate and no httpHostname configured; run 'netsocs config' first")
+			os.Exit(1)
+		}
+		issue([]string{host})
+		return
+	case err != nil:
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	leaf := secret.Chain[0]
+	left := utils.DaysUntil(leaf.NotAfter)
+	switch secret.Source {
+	case utils.CertSourceImported:
+		pterm.Error.Printfln("The certificate was imported and expires in %d days; import the renewed one with 'netsocs cert import'", left)
+		os.Exit(1)
+	case utils.CertSourceACME:
+		pterm.Info.Printfln("The certificate is renewed automatically by the ACME resolver (%d days left)", left)
+		return
+	case utils.CertSourceLocalCA:
+	default:
+		if !force {
+			pterm.Error.Println("The certificate was not created by the CLI; pass --force to replace it with one from the local CA")
+			os.Exit(1)
+		}
+	}
+
+	hosts := utils.CertificateHosts(leaf)
+	if host != "" && !utils.CertificateCovers(leaf, host) {
+		hosts = append([]string{host}, hosts...)
+	} else if left > days && !force {
+		pterm.Success.Printfln("The certificate is valid for %d more days, nothing to do (renews within %d days)", left, days)
+		return
+	}
+	issue(hosts)
+}
+
+func issue(hosts []string) {
+	ca, created, err := utils.IssueLocalCertificate(hosts)
+	if err != nil {
+		pterm.Error.Printfln("Error issuing the certificate: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Issued a new certificate for %s", strings.Join(hosts, ", "))
+	if created {
+		pterm.Warning.Printfln("The local CA was recreated for %s; import %s again on the client machines", strings.Join(hosts, ", "), ca.CertPath)
+	}
+}
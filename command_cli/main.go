@@ -19,7 +19,27 @@ func UpdateCLICommand(cmd *cobra.Command, args []string) {
 		pterm.Info.Println("Updating CLI to the latest version available")
 	}
 
-	if err := utils.DownloadAndReplaceCLI(version); err != nil {
+	source, err := releaseSource(cmd)
+	if err != nil {
+		pterm.Error.Printfln("Error configuring release source: %v", err)
+		os.Exit(1)
+	}
+
+	release, err := utils.FindCLIRelease(source, version)
+	if err != nil {
+		pterm.Error.Printfln("Error finding CLI release: %v", err)
+		os.Exit(1)
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if chartVersion := utils.DeployedChartVersion(); chartVersion != "" {
+		if err := utils.EnsureCompatible(release.Version, chartVersion, force); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if err := utils.DownloadAndReplaceCLI(source, release); err != nil {
 		pterm.Error.Printfln("Error updating CLI: %v", err)
 		os.Exit(1)
 	}
@@ -35,17 +55,28 @@ func ListCLIVersionsCommand(cmd *cobra.Command, args []string, currentVer string
 			currentVer = parts[len(parts)-1]
 		}
 	}
-	versions, err := utils.ListAvailableCLIVersions()
+	source, err := releaseSource(cmd)
+	if err != nil {
+		fmt.Printf("Error configuring release source: %v\n", err)
+		return
+	}
+	versions, err := utils.ListAvailableCLIVersions(source)
 	if err != nil {
 		fmt.Printf("Error fetching CLI versions: %v\n", err)
 		return
 	}
-	fmt.Println("Available CLI versions:")
+	fmt.Printf("Available CLI versions (%s):\n", source.Name())
 	for _, v := range versions {
-		if v == currentVer {
+		if utils.NormalizeVersion(v) == utils.NormalizeVersion(currentVer) {
 			fmt.Printf("* %s (in use)\n", v)
 		} else {
 			fmt.Printf("  %s\n", v)
 		}
 	}
 }
+
+// releaseSource resolves the --source flag shared by the cli subcommands.
+func releaseSource(cmd *cobra.Command) (utils.ReleaseSource, error) {
+	spec, _ := cmd.Flags().GetString("source")
+	return utils.NewReleaseSource(spec)
+}
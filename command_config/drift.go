@@ -0,0 +1,100 @@
+package commandconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+const (
+	driftAdopt   = "Adopt the deployed values into values.yaml"
+	driftApply   = "Re-apply values.yaml with Helm"
+	driftNothing = "Do nothing"
+)
+
+func DriftCommand(cmd *cobra.Command, args []string) {
+	adopt, _ := cmd.Flags().GetBool("adopt")
+	apply, _ := cmd.Flags().GetBool("apply")
+	force, _ := cmd.Flags().GetBool("force")
+	output, _ := cmd.Flags().GetString("output")
+
+	if adopt && apply {
+		pterm.Error.Println("--adopt and --apply cannot be used together")
+		os.Exit(1)
+	}
+
+	drift, err := utils.DetectDrift()
+	if err != nil {
+		pterm.Error.Printfln("Error checking drift: %v", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		changes := drift.Changes
+		if changes == nil {
+			changes = []utils.ValueChange{}
+		}
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"drifted": len(changes) > 0,
+			"changes": changes,
+		}, "", "  ")
+		if err != nil {
+			pterm.Error.Printfln("Error encoding JSON: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else if len(drift.Changes) == 0 {
+		pterm.Success.Println("No drift: values.yaml matches the deployed release")
+		return
+	} else {
+		pterm.Warning.Printfln("values.yaml differs from the deployed release in %d key(s)", len(drift.Changes))
+		pterm.Info.Println("'added' keys exist only in values.yaml, 'removed' keys only in the deployed release")
+		printValueChanges(drift.Changes)
+	}
+
+	if len(drift.Changes) == 0 {
+		return
+	}
+
+	action := driftNothing
+	switch {
+	case adopt:
+		action = driftAdopt
+	case apply:
+		action = driftApply
+	case output != "json" && utils.StdinIsTerminal():
+		prompt := &survey.Select{
+			Message: "How do you want to resolve the drift?",
+			Options: []string{driftNothing, driftAdopt, driftApply},
+		}
+		if err := survey.AskOne(prompt, &action); err != nil {
+			pterm.Error.Printfln("Error reading answer: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	switch action {
+	case driftAdopt:
+		if err := utils.AdoptValues(drift.Deployed, drift.Changes); err != nil {
+			pterm.Error.Printfln("Error adopting deployed values: %v", err)
+			os.Exit(1)
+		}
+		pterm.Success.Println("values.yaml now matches the deployed release")
+	case driftApply:
+		chartVersion := utils.DeployedChartVersion()
+		if err := utils.CheckBeforeUpgrade(chartVersion, force); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		if err := utils.RunHelmUpgradeWithVersion(chartVersion); err != nil {
+			pterm.Error.Printfln("Error running Helm: %v", err)
+			os.Exit(1)
+		}
+		pterm.Success.Println("values.yaml re-applied")
+	}
+}
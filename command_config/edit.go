@@ -0,0 +1,235 @@
+package commandconfig
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/kballard/go-shellquote"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func EditCommand(cmd *cobra.Command, args []string) {
+	yes, _ := cmd.Flags().GetBool("yes")
+	noUpgrade, _ := cmd.Flags().GetBool("no-upgrade")
+	skipValidation, _ := cmd.Flags().GetBool("skip-validation")
+	force, _ := cmd.Flags().GetBool("force")
+
+	if !utils.StdinIsTerminal() {
+		pterm.Error.Println("config edit needs an interactive terminal; use 'config set' in scripts")
+		os.Exit(1)
+	}
+
+	// The edit is validated against and deployed with the deployed chart
+	// version; only "netsocs upgrade" moves to another one.
+	chartVersion := utils.DeployedChartVersion()
+	if !noUpgrade {
+		if err := utils.CheckBeforeUpgrade(chartVersion, force); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	valuesPath, err := utils.ValuesFilePath()
+	if err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+	original, err := os.ReadFile(valuesPath)
+	if err != nil {
+		pterm.Error.Printfln("Error reading %s: %v", valuesPath, err)
+		os.Exit(1)
+	}
+
+	// Work on a private copy so a failed validation never touches values.yaml.
+	tmp, err := os.CreateTemp("", "netsocs-values-*.yaml")
+	if err != nil {
+		pterm.Error.Printfln("Error creating temporary file: %v", err)
+		os.Exit(1)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	if err := os.WriteFile(tmpPath, original, 0600); err != nil {
+		pterm.Error.Printfln("Error creating temporary file: %v", err)
+		os.Exit(1)
+	}
+	keepTmp := false
+	defer func() {
+		if !keepTmp {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	var spec *utils.ChartSpec
+	if !skipValidation {
+		if spec, err = utils.FetchChartSpec(chartVersion); err != nil {
+			pterm.Warning.Printfln("Could not load chart schema, only YAML syntax will be checked: %v", err)
+		}
+	}
+
+	var edited []byte
+	var values map[string]interface{}
+	for {
+		if err := runEditor(tmpPath); err != nil {
+			keepTmp = true
+			pterm.Error.Printfln("Error running editor: %v (your changes are in %s)", err, tmpPath)
+			os.Exit(1)
+		}
+		if edited, err = os.ReadFile(tmpPath); err != nil {
+			pterm.Error.Printfln("Error reading edited file: %v", err)
+			os.Exit(1)
+		}
+		if string(edited) == string(original) {
+			pterm.Info.Println("No changes made")
+			return
+		}
+
+		values, err = validateEdited(edited, spec)
+		if err == nil {
+			break
+		}
+		pterm.Error.Println(err)
+		if !askYesNo("Reopen the editor to fix the errors?", true) {
+			keepTmp = true
+			pterm.Warning.Printfln("values.yaml was not changed. Your edits were kept in %s", tmpPath)
+			os.Exit(1)
+		}
+	}
+
+	changes, err := diffWithDeployed(values)
+	if err == nil {
+		if len(changes) == 0 {
+			pterm.Info.Println("The edited values match the deployed release")
+		} else {
+			pterm.DefaultSection.Println("Changes compared to the deployed release")
+			printValueChanges(changes)
+		}
+	} else {
+		pterm.Warning.Printfln("Could not read deployed values (%v), showing changes to values.yaml", err)
+		utils.PrintDiff(utils.UnifiedDiff("values.yaml", "values.yaml (edited)", original, edited))
+	}
+
+	if !yes && !askYesNo("Save values.yaml and apply the changes?", true) {
+		keepTmp = true
+		pterm.Warning.Printfln("values.yaml was not changed. Your edits were kept in %s", tmpPath)
+		return
+	}
+
+	if err := utils.WriteValuesFile(edited); err != nil {
+		keepTmp = true
+		pterm.Error.Printfln("Error saving values.yaml: %v (your edits are in %s)", err, tmpPath)
+		os.Exit(1)
+	}
+	pterm.Success.Println("values.yaml saved")
+
+	if noUpgrade {
+		pterm.Info.Println("Skipping Helm upgrade (--no-upgrade). Run 'netsocs upgrade' to apply the change.")
+		return
+	}
+	if err := utils.RunHelmUpgradeWithVersion(chartVersion); err != nil {
+		pterm.Error.Printfln("Error running Helm: %v", err)
+		pterm.Info.Println("Use 'netsocs config history' and 'netsocs config restore' to go back to the previous values.")
+		os.Exit(1)
+	}
+	pterm.Success.Println("Configuration applied")
+}
+
+// runEditor opens path in $VISUAL, $EDITOR or vi.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	words, err := shellquote.Split(editor)
+	if err != nil || len(words) == 0 {
+		return fmt.Errorf("invalid editor command %q", editor)
+	}
+
+	editorCmd := exec.Command(words[0], append(words[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	return editorCmd.Run()
+}
+
+// validateEdited checks YAML syntax and, when the chart schema is available,
+// the merged layers with the edited file in place of values.yaml, as "config
+// set" does, so keys kept in site.yaml or secrets.yaml count.
+func validateEdited(content []byte, spec *utils.ChartSpec) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	if spec == nil {
+		return values, nil
+	}
+
+	layered, err := utils.LoadLayeredValues(values)
+	if err != nil {
+		return nil, err
+	}
+	issues := utils.ValidateValues(layered, spec)
+	utils.PrintValidationIssues(issues)
+	for _, issue := range issues {
+		if issue.Severity == utils.SeverityError {
+			return nil, fmt.Errorf("the edited values do not pass the chart schema")
+		}
+	}
+	return values, nil
+}
+
+// diffWithDeployed compares the deployed values with the layers merged with
+// the edited values.yaml.
+func diffWithDeployed(values map[string]interface{}) ([]utils.ValueChange, error) {
+	deployed, err := utils.GetDeployedValues()
+	if err != nil {
+		return nil, err
+	}
+	layered, err := utils.LoadLayeredValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return utils.DiffDeployed(deployed, layered)
+}
+
+func printValueChanges(changes []utils.ValueChange) {
+	tableData := pterm.TableData{{"Key", "Change", "Before", "After"}}
+	for _, change := range changes {
+		before, after := "", ""
+		if change.Kind != "added" {
+			before = utils.FormatValue(change.Old)
+		}
+		if change.Kind != "removed" {
+			after = utils.FormatValue(change.New)
+		}
+		kind := change.Kind
+		switch change.Kind {
+		case "added":
+			kind = pterm.Green(kind)
+		case "removed":
+			kind = pterm.Red(kind)
+		default:
+			kind = pterm.Yellow(kind)
+		}
+		tableData = append(tableData, []string{change.Path, kind, truncate(before, 50), truncate(after, 50)})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+func askYesNo(message string, defaultValue bool) bool {
+	answer := defaultValue
+	if err := survey.AskOne(&survey.Confirm{Message: message, Default: defaultValue}, &answer); err != nil {
+		return false
+	}
+	return answer
+}
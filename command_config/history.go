@@ -0,0 +1,115 @@
+package commandconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func HistoryCommand(cmd *cobra.Command, args []string) {
+	if len(args) == 1 {
+		snapshot, err := utils.GetValuesSnapshot(args[0])
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Snapshot:  %d\n", snapshot.ID)
+		fmt.Printf("Date:      %s\n", snapshot.Timestamp.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Author:    %s\n", snapshot.Author)
+		fmt.Printf("Command:   %s\n\n", snapshot.Command)
+		if snapshot.Diff == "" {
+			fmt.Println("No changes")
+		} else {
+			utils.PrintDiff(snapshot.Diff)
+		}
+		return
+	}
+
+	limit, _ := cmd.Flags().GetInt("limit")
+	snapshots, err := utils.ListValuesSnapshots()
+	if err != nil {
+		pterm.Error.Printfln("Error reading history: %v", err)
+		os.Exit(1)
+	}
+	if len(snapshots) == 0 {
+		pterm.Info.Println("No values.yaml changes recorded yet")
+		return
+	}
+	if limit > 0 && len(snapshots) > limit {
+		snapshots = snapshots[len(snapshots)-limit:]
+	}
+
+	tableData := pterm.TableData{{"ID", "Date", "Author", "Changes", "Command"}}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		added, removed := utils.DiffStats(snapshot.Diff)
+		tableData = append(tableData, []string{
+			fmt.Sprint(snapshot.ID),
+			snapshot.Timestamp.Format("2006-01-02 15:04:05"),
+			snapshot.Author,
+			fmt.Sprintf("+%d -%d", added, removed),
+			truncate(snapshot.Command, 60),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	pterm.Info.Println("Use 'config history <id>' to see a change and 'config restore <id>' to roll back to it.")
+}
+
+func RestoreCommand(cmd *cobra.Command, args []string) {
+	apply, _ := cmd.Flags().GetBool("apply")
+	force, _ := cmd.Flags().GetBool("force")
+
+	snapshot, err := utils.GetValuesSnapshot(args[0])
+	if err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	chartVersion := utils.DeployedChartVersion()
+	if apply {
+		if err := utils.CheckBeforeUpgrade(chartVersion, force); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	valuesPath, err := utils.ValuesFilePath()
+	if err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+	current, _ := os.ReadFile(valuesPath)
+	diff := utils.UnifiedDiff("values.yaml (current)", fmt.Sprintf("values.yaml (snapshot %d)", snapshot.ID), current, []byte(snapshot.Content))
+	if diff == "" {
+		pterm.Info.Printfln("values.yaml already matches snapshot %d", snapshot.ID)
+	} else {
+		utils.PrintDiff(diff)
+		if _, err := utils.RestoreValuesSnapshot(args[0]); err != nil {
+			pterm.Error.Printfln("Error restoring snapshot: %v", err)
+			os.Exit(1)
+		}
+		pterm.Success.Printfln("values.yaml restored to snapshot %d (%s)", snapshot.ID, snapshot.Timestamp.Format("2006-01-02 15:04:05"))
+	}
+
+	if !apply {
+		pterm.Info.Println("Run 'netsocs upgrade' or pass --apply to deploy the restored values.")
+		return
+	}
+	if err := utils.RunHelmUpgradeWithVersion(chartVersion); err != nil {
+		pterm.Error.Printfln("Error running Helm: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Println("Restored values applied")
+}
+
+func truncate(text string, max int) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if len(text) <= max {
+		return text
+	}
+	return text[:max-3] + "..."
+}
@@ -0,0 +1,166 @@
+package commandconfig
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+const defaultProbeTimeout = 3 * time.Minute
+
+type hostnameOptions struct {
+	yes            bool
+	noUpgrade      bool
+	regenerateCert bool
+	skipProbe      bool
+	probeTimeout   time.Duration
+	skipValidation bool
+	force          bool
+}
+
+func HostnameCommand(cmd *cobra.Command, args []string) {
+	host, err := normalizeAddress(args[0])
+	if err != nil {
+		pterm.Error.Printfln("Invalid address %q: %v", args[0], err)
+		os.Exit(1)
+	}
+
+	changeHostname(host, hostnameOptionsFromFlags(cmd))
+}
+
+func hostnameOptionsFromFlags(cmd *cobra.Command) hostnameOptions {
+	var options hostnameOptions
+	options.yes, _ = cmd.Flags().GetBool("yes")
+	options.yes = options.yes || utils.EnvBool("NETSOCS_YES")
+	options.noUpgrade, _ = cmd.Flags().GetBool("no-upgrade")
+	options.noUpgrade = options.noUpgrade || utils.EnvBool("NETSOCS_NO_UPGRADE")
+	options.regenerateCert, _ = cmd.Flags().GetBool("regenerate-cert")
+	options.skipProbe, _ = cmd.Flags().GetBool("skip-probe")
+	options.skipValidation, _ = cmd.Flags().GetBool("skip-validation")
+	options.force, _ = cmd.Flags().GetBool("force")
+	options.probeTimeout, _ = cmd.Flags().GetDuration("probe-timeout")
+	if options.probeTimeout <= 0 {
+		options.probeTimeout = defaultProbeTimeout
+	}
+	return options
+}
+
+// changeHostname updates every host-dependent value, the TLS certificate
+// and the deployment in one go, then checks that the new URL answers.
+func changeHostname(host string, options hostnameOptions) {
+	plan, err := utils.PlanHostnameChange(host)
+	if err != nil {
+		pterm.Error.Printfln("Error reading configuration: %v", err)
+		os.Exit(1)
+	}
+
+	if warning := utils.CheckHostPointsHere(host); warning != "" {
+		pterm.Warning.Println(warning)
+	}
+
+	if len(plan.Changes) == 0 {
+		pterm.Info.Printfln("NETSOCS is already configured for %s", host)
+	} else {
+		pterm.DefaultSection.Println("Changes to values.yaml")
+		printValueChanges(plan.Changes)
+	}
+	if len(plan.Suggestions) > 0 {
+		pterm.DefaultSection.Printfln("Other values mentioning %s", plan.OldHost)
+		printValueChanges(plan.Suggestions)
+		pterm.Info.Println("These are left unchanged since they may point to other services on this host; change them with 'netsocs config set' if they refer to NETSOCS")
+	}
+
+	chartVersion := utils.DeployedChartVersion()
+	if !options.noUpgrade {
+		if err := utils.CheckBeforeUpgrade(chartVersion, options.force); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+	if err := utils.ValidateBeforeApply(plan.Target, chartVersion, options.skipValidation); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	// Check the certificate before writing anything: an imported
+	// certificate that does not cover the new host must stop the change.
+	needCert := false
+	if !options.noUpgrade {
+		needCert, err = utils.CertificateNeeded(host, options.regenerateCert)
+		if err != nil {
+			pterm.Error.Printfln("Certificate: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if !options.yes && !confirm(fmt.Sprintf("Move NETSOCS to %s?", plan.URL)) {
+		pterm.Warning.Println("Configuration cancelled")
+		return
+	}
+
+	if err := plan.Apply(); err != nil {
+		pterm.Error.Printfln("Error updating configuration: %v", err)
+		os.Exit(1)
+	}
+
+	if options.noUpgrade {
+		pterm.Info.Printfln("Skipping the certificate update and Helm upgrade (--no-upgrade). Run 'netsocs config hostname %s' to finish.", host)
+		return
+	}
+
+	generated := false
+	if needCert {
+		ca, created, err := utils.IssueCertificateFor(host)
+		if err != nil {
+			pterm.Error.Printfln("Certificate: %v", err)
+			revertHostname(plan)
+			os.Exit(1)
+		}
+		generated = true
+		pterm.Success.Printfln("Generated a certificate for %s", host)
+		if created {
+			pterm.Warning.Printfln("The local CA was recreated for %s; import %s again on the client machines", host, ca.CertPath)
+		}
+	}
+
+	if err := utils.RunHelmUpgradeWithVersion(chartVersion); err != nil {
+		pterm.Error.Printfln("Error running Helm: %v", err)
+		revertHostname(plan)
+		if generated {
+			pterm.Warning.Printfln("The TLS secret already holds the certificate for %s; run 'netsocs cert generate' to issue one for the previous address", host)
+		}
+		os.Exit(1)
+	}
+
+	if options.skipProbe {
+		pterm.Success.Printfln("¡Configuration completed!")
+		return
+	}
+
+	insecure := generated
+	if secret, err := utils.GetTLSSecret(); err == nil && secret.Managed() {
+		insecure = true
+	}
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Waiting for %s to answer...", plan.URL))
+	if err := utils.ProbeURL(plan.URL, options.probeTimeout, insecure); err != nil {
+		spinner.Fail(err.Error())
+		pterm.Warning.Println("The configuration was applied but NETSOCS is not reachable at the new address yet; check 'netsocs status' and the DNS records")
+		os.Exit(1)
+	}
+	spinner.Success(fmt.Sprintf("NETSOCS answers at %s", plan.URL))
+	pterm.Success.Printfln("¡Configuration completed!")
+}
+
+// revertHostname restores the values.yaml replaced by plan after a later
+// step failed, so that a failed change leaves the configuration as it was.
+func revertHostname(plan *utils.HostnameChange) {
+	if err := plan.Revert(); err != nil {
+		pterm.Error.Printfln("Error restoring the previous values.yaml: %v; see 'netsocs config history' and 'netsocs config restore'", err)
+		return
+	}
+	pterm.Info.Println("values.yaml was restored to its previous content")
+}
@@ -3,7 +3,7 @@ package commandconfig
 import (
 	"fmt"
 	"os"
-	"regexp"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
@@ -12,36 +12,69 @@ import (
 )
 
 func ConfigCommand(cmd *cobra.Command, args []string) {
-	utils.ShowBannerArt()
-	address := promptAddress()
-	// Update the field in values.yaml
-	if err := utils.UpdateChartConfig("httpHostname", "https://"+address); err != nil {
-		pterm.Error.Printfln("Error updating configuration: %v", err)
+	address := flagOrEnv(cmd, "address", "NETSOCS_ADDRESS")
+	options := hostnameOptionsFromFlags(cmd)
+	interactive := utils.StdinIsTerminal()
+
+	if !interactive && (address == "" || !options.yes) {
+		pterm.Error.Println("stdin is not a terminal: pass --address and --yes (or NETSOCS_ADDRESS and NETSOCS_YES=1) to configure non-interactively")
 		os.Exit(1)
 	}
 
-	// Run Helm upgrade
-	if err := utils.RunHelmUpgrade(); err != nil {
-		pterm.Error.Printfln("Error running Helm: %v", err)
+	if address == "" {
+		utils.ShowBannerArt()
+	}
+
+	if address == "" {
+		address = promptAddress()
+	}
+	normalized, err := normalizeAddress(address)
+	if err != nil {
+		pterm.Error.Printfln("Invalid address %q: %v", address, err)
 		os.Exit(1)
 	}
 
-	pterm.Success.Printfln("¡Configuration completed!")
-	// pterm.Info.Printfln("Address configured: %s", pterm.LightGreen(address))
+	changeHostname(normalized, options)
+}
+
+// flagOrEnv returns the flag value when it was set and falls back to the
+// environment variable otherwise.
+func flagOrEnv(cmd *cobra.Command, flag, env string) string {
+	if cmd.Flags().Changed(flag) {
+		value, _ := cmd.Flags().GetString(flag)
+		return value
+	}
+	return os.Getenv(env)
+}
+
+func confirm(message string) bool {
+	ok := false
+	if err := survey.AskOne(&survey.Confirm{Message: message, Default: true}, &ok); err != nil {
+		pterm.Error.Printfln("Error reading confirmation: %v", err)
+		os.Exit(1)
+	}
+	return ok
 }
 
 func promptAddress() string {
+	suggestions := utils.SuggestAddresses()
+	help := "An IPv4 or IPv6 address or a host name, optionally with a port (e.g. server.netsocs.com:8443)"
+	if len(suggestions) > 0 {
+		help += ". This server: " + strings.Join(suggestions, ", ")
+	}
+
 	address := ""
 	prompt := &survey.Input{
 		Message: "NETSOCS Address:",
-		Help:    "Example: 192.168.1.1 or server.netsocs.com",
+		Help:    help,
 		Suggest: func(toComplete string) []string {
-			return []string{
-				"192.168.1.",
-				"10.0.0.",
-				"server.netsocs.com",
-				"dns.netsocs.local",
+			var matches []string
+			for _, suggestion := range suggestions {
+				if strings.HasPrefix(suggestion, strings.ToLower(toComplete)) {
+					matches = append(matches, suggestion)
+				}
 			}
+			return matches
 		},
 	}
 
@@ -50,13 +83,9 @@ func promptAddress() string {
 		if !ok {
 			return fmt.Errorf("invalid data type")
 		}
-		if isValidAddress(str) {
-			return nil
-		}
-		return fmt.Errorf("invalid format: must be IP (XXX.XXX.XXX.XXX) or domain (e.g: dns.netsocs.com)")
+		return validateAddress(str)
 	}
 
-	// survey.AskOne(prompt, &ip, survey.WithValidator(validation))
 	err := survey.AskOne(prompt, &address,
 		survey.WithIcons(func(icons *survey.IconSet) {
 			icons.Question.Text = pterm.Green(">")
@@ -71,20 +100,25 @@ func promptAddress() string {
 	return address
 }
 
-func isValidAddress(address string) bool {
-	// Validation for IP address
-	ipRegex := regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}$`)
-	if ipRegex.MatchString(address) {
-		return true
-	}
+// validateAddress is shared by the prompt and the --address flag so both
+// paths accept exactly the same input.
+func validateAddress(address string) error {
+	_, err := utils.ParseAddress(address)
+	return err
+}
 
-	// Validation for domain
-	domainRegex := regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9](\.[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9])*(\.[a-zA-Z]{2,})$`)
-	if domainRegex.MatchString(address) {
-		return true
+// normalizeAddress validates address, prints its warnings and returns the
+// form stored in the values (ASCII host name, IPv6 in brackets).
+func normalizeAddress(address string) (string, error) {
+	parsed, err := utils.ParseAddress(address)
+	if err != nil {
+		return "", err
 	}
-
-	// Allow local domains without TLD
-	localDomainRegex := regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9](\.[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9])+$`)
-	return localDomainRegex.MatchString(address)
+	for _, warning := range parsed.Warnings() {
+		pterm.Warning.Println(warning)
+	}
+	if normalized := parsed.String(); normalized != address {
+		pterm.Info.Printfln("Using %s", normalized)
+	}
+	return parsed.String(), nil
 }
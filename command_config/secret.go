@@ -0,0 +1,141 @@
+package commandconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func SecretSetCommand(cmd *cobra.Command, args []string) {
+	path := args[0]
+	fromCurrent, _ := cmd.Flags().GetBool("from-current")
+
+	var plaintext string
+	switch {
+	case fromCurrent:
+		values, err := utils.LoadValues()
+		if err != nil {
+			pterm.Error.Printfln("Error reading configuration: %v", err)
+			os.Exit(1)
+		}
+		current, found, err := utils.GetValue(values, path)
+		if err != nil {
+			pterm.Error.Printfln("Invalid path: %v", err)
+			os.Exit(1)
+		}
+		if !found {
+			pterm.Error.Printfln("Key '%s' is not set in values.yaml", path)
+			os.Exit(1)
+		}
+		if utils.IsEncrypted(current) {
+			pterm.Info.Printfln("Key '%s' is already encrypted", path)
+			return
+		}
+		plaintext = utils.FormatValue(current)
+	case len(args) == 2:
+		pterm.Warning.Println("Passing secrets as arguments leaves them in the shell history; omit the value to be prompted or pipe it through stdin")
+		plaintext = args[1]
+	default:
+		var err error
+		if plaintext, err = readSecretValue(path); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	encrypted, err := utils.EncryptSecret(plaintext)
+	if err != nil {
+		pterm.Error.Printfln("Error encrypting value: %v", err)
+		os.Exit(1)
+	}
+	if err := utils.UpdateChartConfig(path, encrypted); err != nil {
+		pterm.Error.Printfln("Error updating configuration: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Secret '%s' stored encrypted (key %s)", path, utils.CurrentSettings().SecretKeyFile)
+	applyIfRequested(cmd)
+}
+
+// readSecretValue prompts for the value without echo, or reads it from stdin
+// when it is not a terminal.
+func readSecretValue(path string) (string, error) {
+	if !utils.StdinIsTerminal() {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("error reading the value from stdin: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	var value, again string
+	if err := survey.AskOne(&survey.Password{Message: fmt.Sprintf("Value for %s:", path)}, &value,
+		survey.WithValidator(survey.Required)); err != nil {
+		return "", err
+	}
+	if err := survey.AskOne(&survey.Password{Message: "Repeat the value:"}, &again); err != nil {
+		return "", err
+	}
+	if value != again {
+		return "", fmt.Errorf("the values do not match")
+	}
+	return value, nil
+}
+
+func SecretListCommand(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+
+	entries, err := utils.ListSecrets()
+	if err != nil {
+		pterm.Error.Printfln("Error listing secrets: %v", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		if entries == nil {
+			entries = []utils.SecretEntry{}
+		}
+		data, _ := json.MarshalIndent(entries, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(entries) == 0 {
+		pterm.Info.Println("No encrypted values found. Use 'netsocs config secret set <path>' to add one")
+		return
+	}
+	tableData := pterm.TableData{{"Key", "Layer", "Key ID", "Status"}}
+	for _, entry := range entries {
+		status := pterm.Green("ok")
+		if !entry.Readable {
+			status = pterm.Red("cannot decrypt")
+		}
+		tableData = append(tableData, []string{entry.Path, entry.Layer, entry.KeyID, status})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+func SecretRotateCommand(cmd *cobra.Command, args []string) {
+	yes, _ := cmd.Flags().GetBool("yes")
+	keyPath := utils.CurrentSettings().SecretKeyFile
+
+	if !yes && !askYesNo(fmt.Sprintf("Generate a new key in %s and re-encrypt every secret?", keyPath), true) {
+		pterm.Info.Println("Key rotation cancelled")
+		return
+	}
+
+	count, err := utils.RotateSecretKey()
+	if err != nil {
+		pterm.Error.Printfln("Error rotating the secret key: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Re-encrypted %d secret(s) with the new key", count)
+	pterm.Info.Printfln("The previous key was kept next to %s to decrypt older history snapshots; delete it once they are no longer needed", keyPath)
+	applyIfRequested(cmd)
+}
@@ -0,0 +1,262 @@
+package commandconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func GetCommand(cmd *cobra.Command, args []string) {
+	path := args[0]
+	output, _ := cmd.Flags().GetString("output")
+
+	defaults, defaultsErr := utils.ChartDefaultValues()
+	if defaultsErr != nil {
+		pterm.Debug.Printfln("Chart defaults unavailable: %v", defaultsErr)
+		defaults = map[string]interface{}{}
+	}
+	effective, origin, err := utils.EffectiveValues(defaults)
+	if err != nil {
+		pterm.Error.Printfln("Error reading configuration: %v", err)
+		os.Exit(1)
+	}
+
+	value, found, err := utils.GetValue(effective, path)
+	if err != nil {
+		pterm.Error.Printfln("Invalid path: %v", err)
+		os.Exit(1)
+	}
+	if !found {
+		pterm.Error.Printfln("Key '%s' is not set and has no chart default", path)
+		os.Exit(1)
+	}
+
+	if err := printValue(value, output); err != nil {
+		pterm.Error.Printfln("Error printing value: %v", err)
+		os.Exit(1)
+	}
+
+	// The notes go to stderr so that the value can be piped, e.g. to jq.
+	notes := pterm.Info.WithWriter(os.Stderr)
+	source, isLeaf := origin[path]
+	if !isLeaf || defaultsErr != nil {
+		return
+	}
+	if source == utils.LayerChartDefaults {
+		notes.Println("Not overridden, showing the chart default")
+		return
+	}
+	notes.Printfln("Set in the %s layer", source)
+	if defaultValue, hasDefault, _ := utils.GetValue(defaults, path); hasDefault &&
+		utils.FormatValue(defaultValue) != utils.FormatValue(value) {
+		notes.Printfln("Chart default: %s", utils.FormatValue(defaultValue))
+	}
+}
+
+func SetCommand(cmd *cobra.Command, args []string) {
+	path, raw := args[0], args[1]
+	valueType, _ := cmd.Flags().GetString("type")
+
+	value, err := utils.ParseTypedValue(raw, valueType)
+	if err != nil {
+		pterm.Error.Printfln("Invalid value: %v", err)
+		os.Exit(1)
+	}
+
+	// Validate the values as they will be after the change, before writing.
+	values, err := utils.LoadValues()
+	if err != nil {
+		pterm.Error.Printfln("Error reading configuration: %v", err)
+		os.Exit(1)
+	}
+	if err := utils.SetValue(values, path, value); err != nil {
+		pterm.Error.Printfln("Invalid path: %v", err)
+		os.Exit(1)
+	}
+	layered, err := utils.LoadLayeredValues(values)
+	if err != nil {
+		pterm.Error.Printfln("Error reading configuration: %v", err)
+		os.Exit(1)
+	}
+	skipValidation, _ := cmd.Flags().GetBool("skip-validation")
+	if err := utils.ValidateBeforeApply(layered, utils.DeployedChartVersion(), skipValidation); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	if err := utils.UpdateChartConfig(path, value); err != nil {
+		pterm.Error.Printfln("Error updating configuration: %v", err)
+		os.Exit(1)
+	}
+	applyIfRequested(cmd)
+}
+
+func UnsetCommand(cmd *cobra.Command, args []string) {
+	if err := utils.UnsetChartConfig(args[0]); err != nil {
+		pterm.Error.Printfln("Error updating configuration: %v", err)
+		os.Exit(1)
+	}
+	applyIfRequested(cmd)
+}
+
+func ValidateCommand(cmd *cobra.Command, args []string) {
+	version, _ := cmd.Flags().GetString("version")
+	if version == "" {
+		version = utils.DeployedChartVersion()
+	}
+	pterm.Info.Printfln("Validating values.yaml against chart %s", displayVersion(version))
+
+	if err := utils.ValidateValuesFile(version); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+	pterm.Success.Println("values.yaml is valid")
+}
+
+func displayVersion(version string) string {
+	if version == "" {
+		return "latest"
+	}
+	return version
+}
+
+func ShowCommand(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	showSources, _ := cmd.Flags().GetBool("effective")
+
+	defaults, err := utils.ChartDefaultValues()
+	if err != nil {
+		pterm.Warning.Printfln("Chart defaults unavailable, showing the local layers only: %v", err)
+		defaults = map[string]interface{}{}
+	}
+	effective, origin, err := utils.EffectiveValues(defaults)
+	if err != nil {
+		pterm.Error.Printfln("Error reading configuration: %v", err)
+		os.Exit(1)
+	}
+
+	if showSources {
+		if err := printWithSources(effective, origin, output); err != nil {
+			pterm.Error.Printfln("Error printing configuration: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if output != "table" {
+		if err := printValue(effective, output); err != nil {
+			pterm.Error.Printfln("Error printing configuration: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	flatEffective := utils.FlattenValues(effective)
+	flatDefaults := utils.FlattenValues(defaults)
+
+	tableData := pterm.TableData{{"Key", "Value", "Chart default"}}
+	for _, key := range utils.SortedKeys(flatEffective) {
+		value := utils.FormatValue(flatEffective[key])
+		defaultColumn := ""
+		if origin[key] != utils.LayerChartDefaults {
+			if def, ok := flatDefaults[key]; !ok {
+				defaultColumn = "(not in chart)"
+			} else if utils.FormatValue(def) != value {
+				defaultColumn = utils.FormatValue(def)
+			}
+		}
+		if defaultColumn != "" {
+			value = pterm.LightGreen(value)
+		}
+		tableData = append(tableData, []string{key, value, defaultColumn})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// printWithSources prints the merged values noting the layer each key came
+// from: a Source column for tables, line comments for YAML and a separate
+// "sources" object for JSON.
+func printWithSources(effective map[string]interface{}, origin map[string]string, output string) error {
+	switch output {
+	case "table":
+		flat := utils.FlattenValues(effective)
+		tableData := pterm.TableData{{"Key", "Value", "Source"}}
+		for _, key := range utils.SortedKeys(flat) {
+			source := origin[key]
+			if source != utils.LayerChartDefaults {
+				source = pterm.LightGreen(source)
+			}
+			tableData = append(tableData, []string{key, utils.FormatValue(flat[key]), source})
+		}
+		return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	case "json":
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"values":  effective,
+			"sources": origin,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "yaml":
+		data, err := utils.AnnotateValues(effective, origin)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", output)
+}
+
+func printValue(value interface{}, output string) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "yaml", "table":
+		if _, isMap := value.(map[string]interface{}); !isMap {
+			if _, isList := value.([]interface{}); !isList {
+				fmt.Println(utils.FormatValue(value))
+				return nil
+			}
+		}
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+	return nil
+}
+
+// applyIfRequested runs the Helm upgrade after a change when --apply is set.
+func applyIfRequested(cmd *cobra.Command) {
+	apply, _ := cmd.Flags().GetBool("apply")
+	if !apply {
+		pterm.Info.Println("Run 'netsocs upgrade' or pass --apply to deploy the change.")
+		return
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	chartVersion := utils.DeployedChartVersion()
+	if err := utils.CheckBeforeUpgrade(chartVersion, force); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+	if err := utils.RunHelmUpgradeWithVersion(chartVersion); err != nil {
+		pterm.Error.Printfln("Error running Helm: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Println("Configuration applied")
+}
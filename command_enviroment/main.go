@@ -1,14 +1,62 @@
 package commandenviroment
 
 import (
+	"os"
+
 	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
 func EnvironmentCommand(cmd *cobra.Command, args []string) {
-	if !utils.CheckNetworkConnection() {
+	hostOK := true
+	if skip, _ := cmd.Flags().GetBool("skip-host"); !skip {
+		sizeName, _ := cmd.Flags().GetString("size")
+		size, err := utils.FindDeploymentSize(sizeName)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		hostOK = utils.CheckHostResources(size)
+	}
+	if skip, _ := cmd.Flags().GetBool("skip-ports"); !skip {
+		var extra []utils.RequiredPort
+		specs, _ := cmd.Flags().GetStringSlice("port")
+		for _, spec := range specs {
+			port, err := utils.ParsePort(spec)
+			if err != nil {
+				pterm.Error.Println(err)
+				os.Exit(1)
+			}
+			extra = append(extra, port)
+		}
+		hostOK = utils.CheckPortsAndFirewall(extra) && hostOK
+	}
+
+	config := utils.CurrentSettings().Connectivity
+	if cmd.Flags().Changed("workers") {
+		config.Workers, _ = cmd.Flags().GetInt("workers")
+	}
+	if cmd.Flags().Changed("timeout") {
+		config.Timeout, _ = cmd.Flags().GetDuration("timeout")
+	}
+	if cmd.Flags().Changed("deadline") {
+		config.Deadline, _ = cmd.Flags().GetDuration("deadline")
+	}
+	extra, _ := cmd.Flags().GetStringSlice("target")
+	for _, target := range extra {
+		config.Targets = append(config.Targets, utils.ConnectivityTarget{URL: target})
+	}
+
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	networkOK := utils.CheckNetworkConnection(config, verbose)
+	if !networkOK {
 		pterm.Error.Println("🚨 Network connection is not working. Please check your internet connection.")
-		return
+	}
+	if !hostOK {
+		pterm.Error.Println("🚨 The host does not meet the requirements. Apply the suggested fixes before installing.")
+	}
+	if !networkOK || !hostOK {
+		os.Exit(1)
 	}
 }
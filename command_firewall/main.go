@@ -0,0 +1,92 @@
+package commandfirewall
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func ApplyCommand(cmd *cobra.Command, args []string) {
+	ports := utils.RequiredPorts()
+	extra, _ := cmd.Flags().GetStringSlice("port")
+	for _, spec := range extra {
+		port, err := utils.ParsePort(spec)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		ports = append(ports, port)
+	}
+
+	firewall := utils.DetectFirewallAsRoot()
+	if firewall == nil {
+		pterm.Success.Println("No active firewall found (ufw, firewalld, nftables); nothing to open")
+		return
+	}
+
+	// Only the ports the firewall blocks are opened, so existing rules are
+	// not duplicated. Ports published by the Kind nodes are forwarded by
+	// Docker and never reach the input rules, so opening them is useless.
+	published := utils.KindPublishedPorts()
+	var blocked, forwarded []utils.RequiredPort
+	for _, port := range ports {
+		if published[port.String()] {
+			forwarded = append(forwarded, port)
+			continue
+		}
+		if allowed, known := firewall.Allows(port); !allowed || !known {
+			blocked = append(blocked, port)
+		}
+	}
+	if len(forwarded) > 0 {
+		var names []string
+		for _, port := range forwarded {
+			names = append(names, port.String())
+		}
+		pterm.Info.Printfln("%s are published by the Kind nodes; Docker forwards them past the %s input rules", strings.Join(names, ", "), firewall.Name)
+	}
+	if len(blocked) == 0 {
+		pterm.Success.Printfln("%s already allows every required port", firewall.Name)
+		return
+	}
+
+	commands := utils.FirewallCommands(firewall, blocked)
+	pterm.DefaultSection.Printfln("Opening %d ports in %s", len(blocked), firewall.Name)
+	for _, port := range blocked {
+		pterm.Info.Printfln("%s  %s", port, port.Purpose)
+	}
+	for _, command := range commands {
+		fmt.Println("  " + strings.Join(command, " "))
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		return
+	}
+	yes, _ := cmd.Flags().GetBool("yes")
+	yes = yes || utils.EnvBool("NETSOCS_YES")
+	if !yes && !utils.StdinIsTerminal() {
+		pterm.Error.Println("stdin is not a terminal: pass --yes (or NETSOCS_YES=1) to change the firewall non-interactively")
+		os.Exit(1)
+	}
+	if !yes {
+		ok := false
+		if err := survey.AskOne(&survey.Confirm{Message: "Run these commands?", Default: true}, &ok); err != nil || !ok {
+			pterm.Warning.Println("Firewall left unchanged")
+			return
+		}
+	}
+
+	if err := utils.ApplyFirewall(firewall, blocked); err != nil {
+		pterm.Error.Printfln("Error updating the firewall: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Opened %d ports in %s", len(blocked), firewall.Name)
+	if firewall.Name == "nftables" {
+		pterm.Warning.Println("nftables rules are lost on reboot unless saved: sudo sh -c 'nft list ruleset > /etc/nftables.conf'")
+	}
+}
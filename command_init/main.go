@@ -8,7 +8,11 @@ import (
 func InitCommand(cmd *cobra.Command, args []string) {
 	utils.ShowBannerArt()
 
-	if err := utils.InitializeHelmSetup(); err != nil {
+	skipValidation, _ := cmd.Flags().GetBool("skip-validation")
+	keepDefaultSecrets, _ := cmd.Flags().GetBool("keep-default-secrets")
+	options := utils.InitOptions{SkipValidation: skipValidation, KeepDefaultSecrets: keepDefaultSecrets}
+
+	if err := utils.InitializeHelmSetup(options); err != nil {
 		cmd.PrintErrf("Helm configuration error: %v\n", err)
 		return
 	}
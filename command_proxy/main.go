@@ -0,0 +1,133 @@
+package commandproxy
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func SetCommand(cmd *cobra.Command, args []string) {
+	httpURL, err := utils.ParseProxyURL(args[0])
+	if err != nil {
+		pterm.Error.Printfln("Invalid proxy URL %q: %v", args[0], err)
+		os.Exit(1)
+	}
+	httpsURL := ""
+	if raw, _ := cmd.Flags().GetString("https-proxy"); raw != "" {
+		if httpsURL, err = utils.ParseProxyURL(raw); err != nil {
+			pterm.Error.Printfln("Invalid proxy URL %q: %v", raw, err)
+			os.Exit(1)
+		}
+	}
+	noProxy, _ := cmd.Flags().GetStringSlice("no-proxy")
+
+	proxy := utils.NewProxySettings(httpURL, httpsURL, noProxy)
+	if err := utils.SaveProxySettings(proxy); err != nil {
+		pterm.Error.Printfln("Error saving the proxy settings: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Proxy %s saved for the CLI and Helm", utils.RedactProxyURL(proxy.HTTP))
+	apply(cmd, proxy)
+	pterm.Info.Println("Run 'netsocs enviroment' to test the connectivity through the proxy")
+}
+
+func UnsetCommand(cmd *cobra.Command, args []string) {
+	if err := utils.SaveProxySettings(utils.ProxySettings{}); err != nil {
+		pterm.Error.Printfln("Error saving the proxy settings: %v", err)
+		os.Exit(1)
+	}
+	pterm.Success.Println("Proxy removed from the CLI settings")
+	apply(cmd, utils.ProxySettings{})
+}
+
+// apply configures the Docker daemon and the Kind nodes unless skipped.
+// Failures are reported without undoing the saved settings, so the command
+// can be run again once the cause is fixed.
+func apply(cmd *cobra.Command, proxy utils.ProxySettings) {
+	failed := false
+	if skip, _ := cmd.Flags().GetBool("skip-docker"); !skip {
+		restart, _ := cmd.Flags().GetBool("restart-docker")
+		if err := utils.ApplyDockerProxy(proxy, restart); err != nil {
+			pterm.Error.Println(err)
+			failed = true
+		} else if restart {
+			pterm.Success.Println("Docker daemon configured and restarted")
+		} else {
+			pterm.Success.Println("Docker daemon configured; it applies after 'systemctl restart docker' (or pass --restart-docker)")
+		}
+	}
+	if skip, _ := cmd.Flags().GetBool("skip-kind"); !skip {
+		if err := utils.ApplyKindProxy(proxy); err != nil {
+			pterm.Error.Println(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func ShowCommand(cmd *cobra.Command, args []string) {
+	status := utils.GetProxyStatus()
+
+	pterm.DefaultSection.Println("CLI settings")
+	if !status.Settings.Enabled() {
+		pterm.Info.Println("No proxy configured")
+	} else {
+		pterm.DefaultTable.WithData(pterm.TableData{
+			{"HTTP proxy", utils.RedactProxyURL(status.Settings.HTTP)},
+			{"HTTPS proxy", utils.RedactProxyURL(status.Settings.HTTPS)},
+			{"No proxy", strings.Join(status.Settings.NoProxy, ", ")},
+		}).Render()
+	}
+
+	pterm.DefaultSection.Println("Environment of the CLI, Helm and kubectl")
+	if len(status.Env) == 0 {
+		pterm.Info.Println("No proxy variables")
+	} else {
+		var names []string
+		for name := range status.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		tableData := pterm.TableData{}
+		for _, name := range names {
+			value := status.Env[name]
+			if name != "NO_PROXY" {
+				value = utils.RedactProxyURL(value)
+			}
+			tableData = append(tableData, []string{name, value})
+		}
+		pterm.DefaultTable.WithData(tableData).Render()
+	}
+
+	pterm.DefaultSection.Println("Docker and Kind")
+	tableData := pterm.TableData{{"Component", "Proxy"}}
+	tableData = append(tableData, []string{"docker daemon", appliedLabel(status.Docker)})
+	var nodes []string
+	for node := range status.Kind {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		tableData = append(tableData, []string{"containerd in " + node, appliedLabel(status.Kind[node])})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// appliedLabel summarizes a proxy drop-in found on a component.
+func appliedLabel(dropIn string) string {
+	if dropIn == "" {
+		return "not configured by netsocs"
+	}
+	for _, line := range strings.Split(dropIn, "\n") {
+		if value, ok := strings.CutPrefix(line, `Environment="HTTPS_PROXY=`); ok {
+			return utils.RedactProxyURL(strings.TrimSuffix(value, `"`))
+		}
+	}
+	return "configured"
+}
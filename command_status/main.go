@@ -2,68 +2,169 @@ package commandstatus
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
 const NETSOCS_VERSION = "3.0.0"
 
+// Nagios plugin exit codes.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+type statusReport struct {
+	Version      string                   `json:"version"`
+	Level        utils.CheckLevel         `json:"level"`
+	Pods         []PodStatus              `json:"pods"`
+	ProblemPods  []string                 `json:"problemPods,omitempty"`
+	PodsError    string                   `json:"podsError,omitempty"`
+	Certificates []utils.CertificateCheck `json:"certificates"`
+}
+
 func StatusHandler(cmd *cobra.Command, args []string) {
 	verbose, _ := cmd.Flags().GetBool("verbose")
+	output, _ := cmd.Flags().GetString("output")
+	var thresholds utils.ExpiryThresholds
+	thresholds.WarningDays, _ = cmd.Flags().GetInt("cert-warning-days")
+	thresholds.CriticalDays, _ = cmd.Flags().GetInt("cert-critical-days")
+
+	switch output {
+	case "text":
+	case "json", "nagios":
+		pterm.DisableOutput()
+	default:
+		pterm.Error.Printfln("Unknown output format %q, use text, json or nagios", output)
+		os.Exit(1)
+	}
 
-	// Show version
-	pterm.DefaultHeader.
-		WithBackgroundStyle(pterm.NewStyle(pterm.BgGreen)).
-		WithTextStyle(pterm.NewStyle(pterm.FgBlack)).
-		Println(" NETSOCS Status " + NETSOCS_VERSION)
-
-	// Check pods
+	report := statusReport{Version: NETSOCS_VERSION}
 	pods, err := GetNetsocsPods()
 	if err != nil {
-		pterm.Error.Printfln("Error checking pods: %v", err)
-		os.Exit(1)
+		report.PodsError = err.Error()
+		report.Level = utils.LevelUnknown
 	}
-
-	// Analyze pod status
-	allHealthy := true
-	var problemPods []string
-
+	report.Pods = pods
 	for _, pod := range pods {
 		if !IsPodHealthy(pod) {
-			allHealthy = false
-			problemPods = append(problemPods, pod.Name)
+			report.ProblemPods = append(report.ProblemPods, pod.Name)
+			report.Level = utils.LevelCritical
 		}
 	}
+	report.Certificates = utils.CheckCertificates(utils.ConfiguredAddress(), thresholds)
+	for _, check := range report.Certificates {
+		if check.Level > report.Level {
+			report.Level = check.Level
+		}
+	}
+
+	switch output {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	case "nagios":
+		printNagios(report, thresholds)
+	default:
+		printText(report, verbose)
+	}
+	// Text and JSON keep the historical codes: 1 only when kubectl failed.
+	if report.PodsError != "" {
+		os.Exit(1)
+	}
+}
+
+func printText(report statusReport, verbose bool) {
+	// Show version
+	pterm.DefaultHeader.
+		WithBackgroundStyle(pterm.NewStyle(pterm.BgGreen)).
+		WithTextStyle(pterm.NewStyle(pterm.FgBlack)).
+		Println(" NETSOCS Status " + report.Version)
 
 	// Show summary
-	if allHealthy {
+	allHealthy := len(report.ProblemPods) == 0
+	switch {
+	case report.PodsError != "":
+		pterm.Error.Printfln("Error checking pods: %v", report.PodsError)
+	case allHealthy:
 		pterm.Success.Println("All NETSOCS services are operational")
-	} else {
-		pterm.Error.Printfln("Problems detected in the following pods: %s", strings.Join(problemPods, ", "))
+	default:
+		pterm.Error.Printfln("Problems detected in the following pods: %s", strings.Join(report.ProblemPods, ", "))
+	}
+
+	for _, check := range report.Certificates {
+		message := fmt.Sprintf("Certificate %s: %s", check.Name, check.Message)
+		switch check.Level {
+		case utils.LevelOK:
+			pterm.Success.Println(message)
+		case utils.LevelWarning, utils.LevelUnknown:
+			pterm.Warning.Println(message)
+		default:
+			pterm.Error.Println(message)
+		}
 	}
 
 	// Show details if verbose or there are errors
 	if verbose || !allHealthy {
-		DisplayPodsStatus(pods)
+		DisplayPodsStatus(report.Pods)
+	}
+}
+
+// printNagios prints a single plugin line with performance data and exits
+// with the matching plugin code.
+func printNagios(report statusReport, thresholds utils.ExpiryThresholds) {
+	var problems, perfdata []string
+	switch {
+	case report.PodsError != "":
+		problems = append(problems, "pods: "+report.PodsError)
+	case len(report.ProblemPods) > 0:
+		problems = append(problems, fmt.Sprintf("%d pods not ready (%s)", len(report.ProblemPods), strings.Join(report.ProblemPods, ", ")))
+	}
+	perfdata = append(perfdata, fmt.Sprintf("pods=%d pods_not_ready=%d;;0", len(report.Pods), len(report.ProblemPods)))
+
+	for _, check := range report.Certificates {
+		if check.Level != utils.LevelOK {
+			problems = append(problems, fmt.Sprintf("certificate %s %s", check.Name, check.Message))
+		}
+		if check.DaysLeft != nil {
+			label := strings.NewReplacer(" ", "_", ":", "_").Replace(check.Name)
+			perfdata = append(perfdata, fmt.Sprintf("'cert_days_%s'=%d;%d:;%d:", label, *check.DaysLeft, thresholds.WarningDays, thresholds.CriticalDays))
+		}
+	}
+
+	summary := "all services operational"
+	if len(problems) > 0 {
+		summary = strings.Join(problems, "; ")
 	}
+	code := map[utils.CheckLevel]int{
+		utils.LevelOK:       nagiosOK,
+		utils.LevelWarning:  nagiosWarning,
+		utils.LevelUnknown:  nagiosUnknown,
+		utils.LevelCritical: nagiosCritical,
+	}[report.Level]
+	fmt.Printf("NETSOCS %s - %s | %s\n", strings.ToUpper(report.Level.String()), summary, strings.Join(perfdata, " "))
+	os.Exit(code)
 }
 
 type PodStatus struct {
-	Name     string
-	Ready    string
-	Status   string
-	Restarts string
-	Age      string
+	Name     string `json:"name"`
+	Ready    string `json:"ready"`
+	Status   string `json:"status"`
+	Restarts string `json:"restarts"`
+	Age      string `json:"age"`
 }
 
 func GetNetsocsPods() ([]PodStatus, error) {
 	// Run kubectl to get NETSOCS pods
-	cmd := exec.Command("kubectl", "get", "pods", "-o=wide")
+	cmd := utils.KubectlCommand("get", "pods", "-o=wide")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = os.Stderr
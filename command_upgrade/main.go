@@ -2,7 +2,6 @@ package commandupgrade
 
 import (
 	"os"
-	"os/exec"
 
 	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
 	"github.com/pterm/pterm"
@@ -18,7 +17,7 @@ func UpgradeCommand(cmd *cobra.Command, args []string) {
 		pterm.Info.Println("Upgrading to the latest version available")
 	}
 
-	cmdResult := exec.Command("helm", "repo", "update")
+	cmdResult := utils.HelmCommand("repo", "update")
 	cmdResult.Stdout = os.Stdout
 	cmdResult.Stderr = os.Stderr
 	if err := cmdResult.Run(); err != nil {
@@ -26,11 +25,29 @@ func UpgradeCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	force, _ := cmd.Flags().GetBool("force")
+	if err := utils.CheckBeforeUpgrade(version, force); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
+	skipValidation, _ := cmd.Flags().GetBool("skip-validation")
+	values, err := utils.LoadLayeredValues(nil)
+	if err != nil {
+		pterm.Error.Printfln("Error reading configuration: %v", err)
+		os.Exit(1)
+	}
+	if err := utils.ValidateBeforeApply(values, version, skipValidation); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+
 	if err := utils.RunHelmUpgradeWithVersion(version); err != nil {
 		pterm.Error.Printfln("Error upgrading application: %v", err)
 		os.Exit(1)
 	}
 
+	utils.RecordDeployedChartVersion()
 	pterm.Success.Println("Upgrade completed successfully!")
 }
 
@@ -50,22 +67,6 @@ func RollbackCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	utils.RecordDeployedChartVersion()
 	pterm.Success.Println("Rollback completed successfully!")
 }
-
-func UpdateCLICommand(cmd *cobra.Command, args []string) {
-	var version string
-	if len(args) > 0 {
-		version = args[0]
-		pterm.Info.Printfln("Updating CLI to version: %s", version)
-	} else {
-		pterm.Info.Println("Updating CLI to the latest version available")
-	}
-
-	if err := utils.DownloadAndReplaceCLI(version); err != nil {
-		pterm.Error.Printfln("Error updating CLI: %v", err)
-		os.Exit(1)
-	}
-
-	pterm.Success.Println("CLI updated successfully! Please restart the CLI.")
-}
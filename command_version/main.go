@@ -0,0 +1,150 @@
+package commandversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+type netsocsInfo struct {
+	Release       string `json:"release,omitempty"`
+	Chart         string `json:"chart,omitempty"`
+	ChartVersion  string `json:"chartVersion,omitempty"`
+	AppVersion    string `json:"appVersion,omitempty"`
+	Revision      string `json:"revision,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Compatibility string `json:"compatibility,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+type versionReport struct {
+	CLI        utils.BuildInfo        `json:"cli"`
+	Netsocs    netsocsInfo            `json:"netsocs"`
+	Tools      []utils.ToolVersion    `json:"tools"`
+	Kubernetes string                 `json:"kubernetes,omitempty"`
+	Components []utils.ComponentImage `json:"components"`
+	Errors     []string               `json:"errors,omitempty"`
+}
+
+func VersionCommand(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		pterm.Error.Printfln("Invalid output format %q (use text or json)", output)
+		os.Exit(1)
+	}
+
+	report := collectVersionReport()
+
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			pterm.Error.Printfln("Error encoding JSON: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	printVersionReport(report)
+}
+
+func collectVersionReport() versionReport {
+	report := versionReport{
+		CLI:   utils.GetBuildInfo(),
+		Tools: utils.GetToolVersions(),
+	}
+
+	rel, err := utils.GetCurrentRelease()
+	if err != nil {
+		report.Netsocs.Error = err.Error()
+	} else {
+		report.Netsocs = netsocsInfo{
+			Release:      rel.Name,
+			Chart:        rel.Chart,
+			ChartVersion: utils.ChartVersionFromRelease(rel.Chart),
+			AppVersion:   rel.AppVersion,
+			Revision:     rel.Revision,
+			Status:       rel.Status,
+		}
+		if report.Netsocs.ChartVersion != "" {
+			status, err := utils.CheckCompatibility(report.CLI.Version, report.Netsocs.ChartVersion)
+			if err != nil {
+				report.Netsocs.Compatibility = fmt.Sprintf("unknown (%v)", err)
+			} else {
+				report.Netsocs.Compatibility = status.String()
+			}
+		}
+	}
+
+	for _, tool := range report.Tools {
+		if tool.Name == "kubectl" {
+			report.Kubernetes = tool.Server
+		}
+	}
+
+	components, err := utils.GetComponentImages()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	if components == nil {
+		components = []utils.ComponentImage{}
+	}
+	report.Components = components
+	return report
+}
+
+func printVersionReport(report versionReport) {
+	pterm.DefaultSection.Println("CLI")
+	fmt.Printf("Version:    %s\n", report.CLI.Version)
+	fmt.Printf("Git commit: %s\n", report.CLI.GitCommit)
+	fmt.Printf("Build date: %s\n", report.CLI.BuildDate)
+	fmt.Printf("Go version: %s\n", report.CLI.GoVersion)
+	fmt.Printf("Platform:   %s\n", report.CLI.Platform)
+
+	pterm.DefaultSection.Println("NETSOCS")
+	if report.Netsocs.Error != "" {
+		fmt.Printf("Release: %s\n", report.Netsocs.Error)
+	} else {
+		fmt.Printf("Chart:         %s\n", report.Netsocs.Chart)
+		fmt.Printf("App version:   %s\n", report.Netsocs.AppVersion)
+		fmt.Printf("Revision:      %s (%s)\n", report.Netsocs.Revision, report.Netsocs.Status)
+		if report.Netsocs.Compatibility != "" {
+			fmt.Printf("Compatibility: %s\n", report.Netsocs.Compatibility)
+		}
+	}
+
+	pterm.DefaultSection.Println("Tools")
+	tableData := pterm.TableData{{"Tool", "Client", "Server", "Notes"}}
+	for _, tool := range report.Tools {
+		tableData = append(tableData, []string{tool.Name, orDash(tool.Client), orDash(tool.Server), tool.Error})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	if report.Kubernetes != "" {
+		fmt.Printf("Kubernetes server: %s\n", report.Kubernetes)
+	}
+
+	pterm.DefaultSection.Println("Components")
+	if len(report.Components) == 0 {
+		fmt.Println("No deployed components found")
+	} else {
+		tableData = pterm.TableData{{"Component", "Tag", "Image"}}
+		for _, component := range report.Components {
+			tableData = append(tableData, []string{component.Component, component.Tag, component.Image})
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	}
+	for _, msg := range report.Errors {
+		pterm.Warning.Println(msg)
+	}
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -5,17 +5,25 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	_ "embed"
 
+	commandcert "github.com/Netsocs-Team/netsocs-manager-cli/command_cert"
 	commandcli "github.com/Netsocs-Team/netsocs-manager-cli/command_cli"
 	commandconfig "github.com/Netsocs-Team/netsocs-manager-cli/command_config"
 	commandenviroment "github.com/Netsocs-Team/netsocs-manager-cli/command_enviroment"
+	commandfirewall "github.com/Netsocs-Team/netsocs-manager-cli/command_firewall"
 	commandinit "github.com/Netsocs-Team/netsocs-manager-cli/command_init"
+	commandproxy "github.com/Netsocs-Team/netsocs-manager-cli/command_proxy"
 	commandstatus "github.com/Netsocs-Team/netsocs-manager-cli/command_status"
 	commandupgrade "github.com/Netsocs-Team/netsocs-manager-cli/command_upgrade"
+	commandversion "github.com/Netsocs-Team/netsocs-manager-cli/command_version"
 	"github.com/Netsocs-Team/netsocs-manager-cli/utils"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
 )
 
 //go:embed version
@@ -29,10 +37,61 @@ func GetVersion() string {
 	return string(version)
 }
 
+var updateNotifier *utils.UpdateNotifier
+
 var rootCmd = &cobra.Command{
 	Use:     "netsocs-manager-cli",
 	Short:   "Server configuration tool",
 	Version: version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if _, err := utils.LoadSettings(settingsFromFlags(cmd)); err != nil {
+			pterm.Error.Printfln("Invalid CLI settings: %v", err)
+			os.Exit(1)
+		}
+		var flags []string
+		cmd.Flags().Visit(func(flag *pflag.Flag) { flags = append(flags, flag.Name) })
+		utils.SetInvokedCommand(strings.Fields(cmd.CommandPath())[1:], flags)
+		if updateCheckEnabled(cmd) {
+			updateNotifier = utils.StartUpdateCheck(version)
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if updateNotifier != nil {
+			updateNotifier.PrintNotice()
+		}
+	},
+}
+
+// settingsFromFlags returns the settings given as global flags. Empty
+// fields fall back to the environment and the CLI config file.
+func settingsFromFlags(cmd *cobra.Command) utils.Settings {
+	flag := func(name string) string {
+		value, _ := cmd.Flags().GetString(name)
+		return value
+	}
+	return utils.Settings{
+		Home:        flag("home"),
+		ValuesFile:  flag("values-file"),
+		ReleaseName: flag("release"),
+		Namespace:   flag("namespace"),
+		KubeContext: flag("kube-context"),
+		Chart:       flag("chart"),
+	}
+}
+
+// updateCheckEnabled skips the background update check when it was disabled,
+// when output is not a terminal or when a machine-readable format is requested.
+func updateCheckEnabled(cmd *cobra.Command) bool {
+	if disabled, _ := cmd.Flags().GetBool("no-update-check"); disabled || utils.UpdateChecksDisabled() {
+		return false
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return false
+	}
+	if output, err := cmd.Flags().GetString("output"); err == nil && output != "" && output != "text" {
+		return false
+	}
+	return true
 }
 
 type ChartValues struct {
@@ -48,9 +107,109 @@ var initCmd = &cobra.Command{
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Base configuration for Netsocs",
+	Args:  cobra.NoArgs,
 	Run:   commandconfig.ConfigCommand,
 }
 
+var configGetCmd = &cobra.Command{
+	Use:   "get <path>",
+	Short: "Show a values.yaml key (e.g. ingress.hosts[0].host) and its chart default",
+	Args:  cobra.ExactArgs(1),
+	Run:   commandconfig.GetCommand,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <path> <value>",
+	Short: "Set a values.yaml key",
+	Long: `Set a values.yaml key. Paths are dotted keys with list indexes, e.g.
+ingress.hosts[0].host; an index equal to the list length appends an item.
+Escape dots in keys with a backslash: 'podAnnotations.netsocs\.com/tier'.`,
+	Args: cobra.ExactArgs(2),
+	Run:  commandconfig.SetCommand,
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <path>",
+	Short: "Remove a values.yaml key so the chart default applies",
+	Args:  cobra.ExactArgs(1),
+	Run:   commandconfig.UnsetCommand,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate values.yaml against the chart schema and defaults",
+	Args:  cobra.NoArgs,
+	Run:   commandconfig.ValidateCommand,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration (chart defaults merged with site.yaml, values.yaml and secrets.yaml)",
+	Args:  cobra.NoArgs,
+	Run:   commandconfig.ShowCommand,
+}
+
+var configHistoryCmd = &cobra.Command{
+	Use:   "history [id]",
+	Short: "List the recorded values.yaml changes, or show one of them",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   commandconfig.HistoryCommand,
+}
+
+var configRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Restore values.yaml from a history snapshot",
+	Args:  cobra.ExactArgs(1),
+	Run:   commandconfig.RestoreCommand,
+}
+
+var configHostnameCmd = &cobra.Command{
+	Use:   "hostname <address>",
+	Short: "Move NETSOCS to a new address, updating dependent values, the certificate and the deployment",
+	Args:  cobra.ExactArgs(1),
+	Run:   commandconfig.HostnameCommand,
+}
+
+var configSecretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage encrypted values (passwords, API keys) in values.yaml",
+}
+
+var configSecretSetCmd = &cobra.Command{
+	Use:   "set <path> [value]",
+	Short: "Encrypt a value and store it in values.yaml (prompts for the value or reads it from stdin)",
+	Args:  cobra.RangeArgs(1, 2),
+	Run:   commandconfig.SecretSetCommand,
+}
+
+var configSecretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the encrypted values without showing them",
+	Args:  cobra.NoArgs,
+	Run:   commandconfig.SecretListCommand,
+}
+
+var configSecretRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Generate a new secret key and re-encrypt every secret with it",
+	Args:  cobra.NoArgs,
+	Run:   commandconfig.SecretRotateCommand,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit values.yaml in $EDITOR, validate it and apply the changes",
+	Args:  cobra.NoArgs,
+	Run:   commandconfig.EditCommand,
+}
+
+var configDriftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Compare values.yaml with the values of the deployed release",
+	Args:  cobra.NoArgs,
+	Run:   commandconfig.DriftCommand,
+}
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Shows the status of NETSOCS",
@@ -79,11 +238,8 @@ var environmentCmd = &cobra.Command{
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
-	Short: "Show CLI and netsocs version",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("CLI version: %s\n", version)
-		fmt.Printf("Netsocs version: %s\n", utils.GetCurrentAppVersion())
-	},
+	Short: "Show CLI build information, tool versions and deployed NETSOCS components",
+	Run:   commandversion.VersionCommand,
 }
 
 var listVersionsCmd = &cobra.Command{
@@ -134,6 +290,84 @@ var cliListVersionsCmd = &cobra.Command{
 	},
 }
 
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Manage the TLS certificate served by NETSOCS",
+}
+
+var certGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Issue a certificate from a local CA for IP or private-domain installs",
+	Args:  cobra.NoArgs,
+	Run:   commandcert.GenerateCommand,
+}
+
+var certImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a PEM or PFX certificate into the TLS secret",
+	Args:  cobra.NoArgs,
+	Run:   commandcert.ImportCommand,
+}
+
+var certShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the certificate chain and its expiry",
+	Args:  cobra.NoArgs,
+	Run:   commandcert.ShowCommand,
+}
+
+var certRenewCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "Reissue the local CA certificate when it is about to expire or does not match the address",
+	Args:  cobra.NoArgs,
+	Run:   commandcert.RenewCommand,
+}
+
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "Manage the host firewall rules NETSOCS needs",
+}
+
+var firewallApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Open exactly the ports NETSOCS needs in ufw, firewalld or nftables",
+	Args:  cobra.NoArgs,
+	Run:   commandfirewall.ApplyCommand,
+}
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Configure the HTTP(S) proxy used by the CLI, Helm, Docker and the Kind nodes",
+}
+
+var proxySetCmd = &cobra.Command{
+	Use:   "set <url>",
+	Short: "Save a proxy and apply it to the CLI, Helm, the Docker daemon and containerd in Kind",
+	Args:  cobra.ExactArgs(1),
+	Run:   commandproxy.SetCommand,
+}
+
+var proxyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the proxy settings and where they are applied",
+	Args:  cobra.NoArgs,
+	Run:   commandproxy.ShowCommand,
+}
+
+var proxyUnsetCmd = &cobra.Command{
+	Use:   "unset",
+	Short: "Remove the proxy from the CLI settings, Docker and the Kind nodes",
+	Args:  cobra.NoArgs,
+	Run:   commandproxy.UnsetCommand,
+}
+
+var certAcmeCmd = &cobra.Command{
+	Use:   "acme",
+	Short: "Obtain and renew certificates automatically from Let's Encrypt or another ACME server",
+	Args:  cobra.NoArgs,
+	Run:   commandcert.AcmeCommand,
+}
+
 var autoInstallCmd = &cobra.Command{
 	Use:   "auto-install",
 	Short: "Installs the CLI as 'netsocs' in /usr/local/bin for all users",
@@ -172,20 +406,145 @@ var autoInstallCmd = &cobra.Command{
 }
 
 func init() {
+	utils.CLIVersion = version
+	rootCmd.PersistentFlags().String("home", "", "NETSOCS home directory with values files and history (env NETSOCS_HOME, default ~/netsocs)")
+	rootCmd.PersistentFlags().String("values-file", "", "Per-server values file (env NETSOCS_VALUES, default <home>/values.yaml)")
+	rootCmd.PersistentFlags().String("release", "", "Helm release name (env NETSOCS_RELEASE, default netsocs)")
+	rootCmd.PersistentFlags().String("namespace", "", "Kubernetes namespace of the release (env NETSOCS_NAMESPACE, default: current context namespace)")
+	rootCmd.PersistentFlags().String("kube-context", "", "Kubeconfig context to use (env NETSOCS_KUBE_CONTEXT)")
+	rootCmd.PersistentFlags().String("chart", "", "Chart reference as <repo>/<chart> (env NETSOCS_CHART, default netsocs/netsocs-helm-chart)")
+	rootCmd.PersistentFlags().Bool("no-update-check", false, "Do not check for newer CLI and NETSOCS versions (env NETSOCS_NO_UPDATE_CHECK)")
 	initCmd.Flags().Bool("ignore-network-check", false, "Skip network connection check")
+	initCmd.Flags().Bool("skip-validation", false, "Do not validate values.yaml against the chart schema")
+	initCmd.Flags().Bool("keep-default-secrets", false, "Keep the chart's default passwords instead of generating random ones")
 	rootCmd.AddCommand(initCmd)
+	configCmd.Flags().String("address", "", "NETSOCS address (IPv4, IPv6 or host name, optional :port) to configure without prompting (env NETSOCS_ADDRESS)")
+	configCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation (env NETSOCS_YES)")
+	configCmd.Flags().Bool("no-upgrade", false, "Only update values.yaml, do not run the Helm upgrade (env NETSOCS_NO_UPGRADE)")
+	configCmd.Flags().Bool("force", false, "Continue even if the CLI is not compatible with the deployed NETSOCS version")
+	configGetCmd.Flags().StringP("output", "o", "yaml", "Output format: yaml or json")
+	configSetCmd.Flags().StringP("type", "t", "string", "Value type: string, int, bool, list, json or auto")
+	configSetCmd.Flags().Bool("apply", false, "Run the Helm upgrade after the change")
+	configSetCmd.Flags().Bool("skip-validation", false, "Do not validate the values against the chart schema")
+	configSetCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configValidateCmd.Flags().String("version", "", "Chart version to validate against (default: deployed version, or latest)")
+	configUnsetCmd.Flags().Bool("apply", false, "Run the Helm upgrade after the change")
+	configUnsetCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configShowCmd.Flags().StringP("output", "o", "table", "Output format: table, yaml or json")
+	configShowCmd.Flags().Bool("effective", false, "Show which layer (chart defaults, site, server, secrets) each key comes from")
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configHistoryCmd.Flags().IntP("limit", "n", 20, "Number of snapshots to list (0 for all)")
+	configRestoreCmd.Flags().Bool("apply", false, "Run the Helm upgrade after restoring")
+	configRestoreCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configEditCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation before saving")
+	configEditCmd.Flags().Bool("no-upgrade", false, "Only save values.yaml, do not run the Helm upgrade")
+	configEditCmd.Flags().Bool("skip-validation", false, "Only check YAML syntax, not the chart schema")
+	configEditCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configCmd.AddCommand(configEditCmd)
+	configDriftCmd.Flags().Bool("adopt", false, "Update values.yaml with the deployed values")
+	configDriftCmd.Flags().Bool("apply", false, "Re-apply values.yaml with Helm")
+	configDriftCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configDriftCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	configCmd.AddCommand(configDriftCmd)
+	configCmd.AddCommand(configHistoryCmd)
+	configCmd.AddCommand(configRestoreCmd)
+	configSecretSetCmd.Flags().Bool("from-current", false, "Encrypt the plaintext value already in values.yaml")
+	configSecretSetCmd.Flags().Bool("apply", false, "Run the Helm upgrade after the change")
+	configSecretSetCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configSecretListCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	configSecretRotateCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	configSecretRotateCmd.Flags().Bool("apply", false, "Run the Helm upgrade after the rotation")
+	configSecretRotateCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	configSecretCmd.AddCommand(configSecretSetCmd)
+	configSecretCmd.AddCommand(configSecretListCmd)
+	configSecretCmd.AddCommand(configSecretRotateCmd)
+	configCmd.AddCommand(configSecretCmd)
+	configHostnameCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation (env NETSOCS_YES)")
+	configHostnameCmd.Flags().Bool("no-upgrade", false, "Only update values.yaml, do not touch the certificate or run the Helm upgrade (env NETSOCS_NO_UPGRADE)")
+	configHostnameCmd.Flags().Bool("force", false, "Continue even if the CLI is not compatible with the deployed NETSOCS version")
+	configHostnameCmd.Flags().Bool("regenerate-cert", false, "Replace the TLS certificate even if it was imported or already covers the address")
+	configHostnameCmd.Flags().Bool("skip-probe", false, "Do not wait for the new URL to answer")
+	configHostnameCmd.Flags().Duration("probe-timeout", 3*time.Minute, "How long to wait for the new URL to answer")
+	configHostnameCmd.Flags().Bool("skip-validation", false, "Do not validate the values against the chart schema")
+	configCmd.AddCommand(configHostnameCmd)
 	rootCmd.AddCommand(configCmd)
 	statusCmd.Flags().BoolP("verbose", "v", false, "Show full pod details")
+	statusCmd.Flags().StringP("output", "o", "text", "Output format: text, json or nagios (plugin line and exit code)")
+	statusCmd.Flags().Int("cert-warning-days", 30, "Warn when a certificate expires within this many days")
+	statusCmd.Flags().Int("cert-critical-days", 7, "Report critical when a certificate expires within this many days")
 	rootCmd.AddCommand(statusCmd)
+	upgradeCmd.Flags().Bool("skip-validation", false, "Do not validate values.yaml against the chart schema")
+	upgradeCmd.Flags().Bool("force", false, "Upgrade even if the target version is not compatible with this CLI")
 	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(rollbackCmd)
+	versionCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(listVersionsCmd)
 	// CLI group
+	cliCmd.PersistentFlags().String("source", "", "Release source: 'github', an https:// mirror URL or a local directory (env NETSOCS_RELEASE_SOURCE; GitHub token from NETSOCS_GITHUB_TOKEN)")
+	cliUpdateCmd.Flags().Bool("force", false, "Update even if the new CLI is not compatible with the deployed NETSOCS version")
 	cliCmd.AddCommand(cliUpdateCmd)
 	cliCmd.AddCommand(cliListVersionsCmd)
 	rootCmd.AddCommand(cliCmd)
+	certGenerateCmd.Flags().StringSlice("host", nil, "Host name or IP for the certificate, repeatable (default: the configured address)")
+	certGenerateCmd.Flags().BoolP("yes", "y", false, "Replace the current certificate without asking")
+	certImportCmd.Flags().String("cert", "", "PEM file with the certificate and its intermediates")
+	certImportCmd.Flags().String("key", "", "PEM file with the private key (default: read from --cert)")
+	certImportCmd.Flags().String("pfx", "", "PKCS#12 (.pfx/.p12) file with certificate and key")
+	certImportCmd.Flags().String("password-file", "", "File with the PFX password (env NETSOCS_PFX_PASSWORD)")
+	certImportCmd.Flags().BoolP("yes", "y", false, "Replace the current certificate without asking")
+	certShowCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	certRenewCmd.Flags().Int("days", 30, "Renew when the certificate expires within this many days")
+	certRenewCmd.Flags().Bool("force", false, "Renew now, and replace certificates not created by the CLI")
+	certAcmeCmd.Flags().String("email", "", "Email for expiry notices from the CA")
+	certAcmeCmd.Flags().String("challenge", "http", "ACME challenge: http (port 80 open to the internet) or dns")
+	certAcmeCmd.Flags().String("dns-provider", "", "DNS provider for the dns challenge: cloudflare, route53, digitalocean, azuredns, gcloud or ovh; credentials are read from the provider's environment variables")
+	certAcmeCmd.Flags().String("server", "", "ACME directory URL, e.g. a Pebble server for testing (default: Let's Encrypt)")
+	certAcmeCmd.Flags().Bool("staging", false, "Use the Let's Encrypt staging server")
+	certAcmeCmd.Flags().String("ca-file", "", "PEM root certificate of a private ACME server, trusted by the CLI and Traefik")
+	certAcmeCmd.Flags().Bool("skip-check", false, "Do not check the ACME server and the challenge before enabling")
+	certAcmeCmd.Flags().Bool("disable", false, "Turn the ACME resolver off")
+	certAcmeCmd.Flags().Bool("no-upgrade", false, "Only update values.yaml, do not run the Helm upgrade")
+	certAcmeCmd.Flags().Bool("force", false, "Deploy even if the CLI is not compatible with the deployed NETSOCS version")
+	certAcmeCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	certCmd.AddCommand(certGenerateCmd)
+	certCmd.AddCommand(certImportCmd)
+	certCmd.AddCommand(certShowCmd)
+	certCmd.AddCommand(certRenewCmd)
+	certCmd.AddCommand(certAcmeCmd)
+	rootCmd.AddCommand(certCmd)
+
+	firewallApplyCmd.Flags().StringSlice("port", nil, "Extra port to open, like 8443/tcp, repeatable")
+	firewallApplyCmd.Flags().Bool("dry-run", false, "Only print the firewall commands")
+	firewallApplyCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation, required without a terminal (env NETSOCS_YES)")
+	firewallCmd.AddCommand(firewallApplyCmd)
+	rootCmd.AddCommand(firewallCmd)
+
+	proxySetCmd.Flags().String("https-proxy", "", "Proxy for HTTPS requests (default: the same as <url>)")
+	proxySetCmd.Flags().StringSlice("no-proxy", nil, "Extra hosts, domains (.example.com) or CIDRs reached directly, added to localhost, the cluster ranges and the configured address")
+	for _, c := range []*cobra.Command{proxySetCmd, proxyUnsetCmd} {
+		c.Flags().Bool("skip-docker", false, "Do not configure the Docker daemon")
+		c.Flags().Bool("restart-docker", false, "Restart the Docker daemon so it uses the change now; the Kind nodes restart with it")
+		c.Flags().Bool("skip-kind", false, "Do not configure containerd in the Kind nodes")
+	}
+	proxyCmd.AddCommand(proxySetCmd)
+	proxyCmd.AddCommand(proxyShowCmd)
+	proxyCmd.AddCommand(proxyUnsetCmd)
+	rootCmd.AddCommand(proxyCmd)
 	rootCmd.AddCommand(autoInstallCmd)
+	environmentCmd.Flags().StringSlice("target", nil, "Extra target to check: an http(s) URL, tcp://host:port or ntp://host, repeatable")
+	environmentCmd.Flags().Int("workers", 8, "Number of targets checked at the same time (config connectivity.workers)")
+	environmentCmd.Flags().Duration("timeout", 10*time.Second, "Timeout of each check (config connectivity.timeout)")
+	environmentCmd.Flags().Duration("deadline", time.Minute, "Maximum duration of the whole run (config connectivity.deadline)")
+	environmentCmd.Flags().String("size", utils.DefaultDeploymentSize, "Deployment size for the host thresholds: small, medium or large")
+	environmentCmd.Flags().Bool("skip-ports", false, "Skip the port and firewall checks")
+	environmentCmd.Flags().StringSlice("port", nil, "Extra port NETSOCS needs, like 8443/tcp, repeatable")
+	environmentCmd.Flags().Bool("skip-host", false, "Skip the CPU, memory, disk and kernel checks of the host")
+	environmentCmd.Flags().BoolP("verbose", "v", false, "Show the DNS, TCP, TLS and HTTP layers of every target, not only the failed ones")
 	rootCmd.AddCommand(environmentCmd)
 }
 
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Chart values of the Traefik ACME resolver. Credentials of DNS providers
+// go under tls.acme.env as the environment variables Traefik expects, and
+// are stored encrypted.
+const (
+	acmeValuesPath = "tls.acme"
+
+	ACMEChallengeHTTP = "http"
+	ACMEChallengeDNS  = "dns"
+
+	LetsEncryptServer        = "https://acme-v02.api.letsencrypt.org/directory"
+	LetsEncryptStagingServer = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+// DNSProvider is a DNS-01 provider supported by Traefik.
+type DNSProvider struct {
+	Name string
+	// Env lists the environment variables with the credentials.
+	Env []string
+	// NameServerHint is part of the name servers of domains hosted by the
+	// provider, used to warn about a provider that does not match the zone.
+	NameServerHint string
+}
+
+// DNSProviders are the common providers offered by "cert acme".
+var DNSProviders = []DNSProvider{
+	{Name: "cloudflare", Env: []string{"CF_DNS_API_TOKEN"}, NameServerHint: "cloudflare.com"},
+	{Name: "route53", Env: []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_REGION"}, NameServerHint: "awsdns"},
+	{Name: "digitalocean", Env: []string{"DO_AUTH_TOKEN"}, NameServerHint: "digitalocean.com"},
+	{Name: "azuredns", Env: []string{"AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "AZURE_TENANT_ID", "AZURE_SUBSCRIPTION_ID", "AZURE_RESOURCE_GROUP"}, NameServerHint: "azure-dns"},
+	{Name: "gcloud", Env: []string{"GCE_PROJECT", "GCE_SERVICE_ACCOUNT"}, NameServerHint: "googledomains.com"},
+	{Name: "ovh", Env: []string{"OVH_ENDPOINT", "OVH_APPLICATION_KEY", "OVH_APPLICATION_SECRET", "OVH_CONSUMER_KEY"}, NameServerHint: "ovh.net"},
+}
+
+// FindDNSProvider returns the provider with the given name.
+func FindDNSProvider(name string) (DNSProvider, error) {
+	var names []string
+	for _, provider := range DNSProviders {
+		if provider.Name == name {
+			return provider, nil
+		}
+		names = append(names, provider.Name)
+	}
+	return DNSProvider{}, fmt.Errorf("unknown DNS provider %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// ACMEConfig is the ACME setup written to the chart values.
+type ACMEConfig struct {
+	Email     string
+	Server    string
+	Challenge string
+	Provider  string
+	// Credentials are the plaintext provider credentials; they are
+	// encrypted before being written.
+	Credentials map[string]string
+	// CACertificate is the PEM root of a private ACME server such as Pebble.
+	CACertificate string
+}
+
+// ACMEEnabled reports whether the values enable the ACME resolver.
+func ACMEEnabled() bool {
+	values, err := LoadLayeredValues(nil)
+	if err != nil {
+		return false
+	}
+	enabled, _, _ := GetValue(values, acmeValuesPath+".enabled")
+	return enabled == true
+}
+
+// CheckACMEDomain rejects names a public ACME server cannot validate: IP
+// addresses, single-label and private-use names.
+func CheckACMEDomain(host string) error {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return fmt.Errorf("%s is an IP address; use 'netsocs cert generate' for IP installs", host)
+	}
+	if !strings.Contains(host, ".") {
+		return fmt.Errorf("%s is not a fully qualified domain name", host)
+	}
+	for _, suffix := range []string{".local", ".lan", ".internal", ".localhost", ".home.arpa", ".corp"} {
+		if strings.HasSuffix(host, suffix) {
+			return fmt.Errorf("%s is a private name that public ACME servers cannot validate; use 'netsocs cert generate'", host)
+		}
+	}
+	return nil
+}
+
+// CheckACMEDirectory fetches the directory of the ACME server, trusting
+// caFile in addition to the system roots when given.
+func CheckACMEDirectory(server, caFile string) error {
+	client := NewHTTPClient(15 * time.Second)
+	if caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%s does not contain PEM certificates", caFile)
+		}
+		client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	resp, err := client.Get(server)
+	if err != nil {
+		return fmt.Errorf("ACME server %s is not reachable: %w", server, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ACME server %s answered HTTP %d", server, resp.StatusCode)
+	}
+	var directory struct {
+		NewNonce   string `json:"newNonce"`
+		NewAccount string `json:"newAccount"`
+		NewOrder   string `json:"newOrder"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&directory); err != nil ||
+		directory.NewNonce == "" || directory.NewAccount == "" || directory.NewOrder == "" {
+		return fmt.Errorf("%s is not an ACME directory", server)
+	}
+	return nil
+}
+
+// CheckHTTPChallengePath checks that http://host/.well-known/acme-challenge/
+// answers on port 80 from this machine. It cannot tell whether the ACME
+// server reaches it from the internet, since firewalls and NAT in front of
+// this machine are not visible. The request must not fail, redirect to
+// another host or end in a server error; an answer other than the 404 an
+// ACME resolver gives for an unknown token is returned as a warning, since
+// a catch-all route would hide the challenges from the resolver.
+func CheckHTTPChallengePath(host string) (string, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "80"), 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("port 80 of %s is not reachable, HTTP-01 needs it open to the internet: %w", host, err)
+	}
+	conn.Close()
+	return checkChallengeURL("http://" + host)
+}
+
+func checkChallengeURL(baseURL string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	client := NewHTTPClient(10 * time.Second)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Hostname() != base.Hostname() {
+			return fmt.Errorf("redirected to %s", req.URL.Host)
+		}
+		return nil
+	}
+	challengeURL := baseURL + "/.well-known/acme-challenge/netsocs-cli-check"
+	resp, err := client.Get(challengeURL)
+	if err != nil {
+		return "", fmt.Errorf("%s is not reachable: %w", challengeURL, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return "", fmt.Errorf("%s answered HTTP %d", challengeURL, resp.StatusCode)
+	case resp.StatusCode != http.StatusNotFound:
+		return fmt.Sprintf("%s answered HTTP %d instead of 404 for an unknown token; check that the ingress routes the challenge path to the ACME resolver", challengeURL, resp.StatusCode), nil
+	}
+	return "", nil
+}
+
+// CheckDNSProviderZone warns when the name servers of host's zone do not
+// belong to provider.
+func CheckDNSProviderZone(host string, provider DNSProvider) string {
+	labels := strings.Split(host, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		zone := strings.Join(labels[i:], ".")
+		servers, err := net.LookupNS(zone)
+		if err != nil || len(servers) == 0 {
+			continue
+		}
+		var names []string
+		for _, server := range servers {
+			if strings.Contains(server.Host, provider.NameServerHint) {
+				return ""
+			}
+			names = append(names, strings.TrimSuffix(server.Host, "."))
+		}
+		sort.Strings(names)
+		return fmt.Sprintf("the name servers of %s (%s) do not look like %s ones", zone, strings.Join(names, ", "), provider.Name)
+	}
+	return fmt.Sprintf("could not find the name servers of %s", host)
+}
+
+// EnableACME writes the ACME configuration to values.yaml, replacing any
+// previous one.
+func EnableACME(config ACMEConfig) error {
+	acme := map[string]interface{}{
+		"enabled":   true,
+		"email":     config.Email,
+		"server":    config.Server,
+		"challenge": config.Challenge,
+	}
+	if config.Challenge == ACMEChallengeDNS {
+		acme["dnsProvider"] = config.Provider
+		env := map[string]interface{}{}
+		for name, value := range config.Credentials {
+			encrypted, err := EncryptSecret(value)
+			if err != nil {
+				return err
+			}
+			env[name] = encrypted
+		}
+		acme["env"] = env
+	}
+	if config.CACertificate != "" {
+		acme["caCertificate"] = config.CACertificate
+	}
+
+	return editValuesFile(func(doc *yaml.Node) (bool, error) {
+		if _, err := UnsetNodeValue(doc, acmeValuesPath); err != nil {
+			return false, err
+		}
+		if err := SetNodeValue(doc, acmeValuesPath, acme); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+// DisableACME turns the ACME resolver off, keeping the rest of its settings.
+func DisableACME() error {
+	return UpdateChartConfig(acmeValuesPath+".enabled", false)
+}
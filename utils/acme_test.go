@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckChallengeURL(t *testing.T) {
+	const challengePath = "/.well-known/acme-challenge/netsocs-cli-check"
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantWarning string
+		wantErr     string
+	}{
+		{
+			name: "resolver answers 404",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != challengePath {
+					t.Errorf("requested %s", r.URL.Path)
+				}
+				http.NotFound(w, r)
+			},
+		},
+		{
+			name: "redirect on the same host",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == challengePath {
+					http.Redirect(w, r, "/moved"+challengePath, http.StatusFound)
+					return
+				}
+				http.NotFound(w, r)
+			},
+		},
+		{
+			name: "catch-all route",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html>NETSOCS</html>"))
+			},
+			wantWarning: "HTTP 200 instead of 404",
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantErr: "HTTP 502",
+		},
+		{
+			name: "redirect to another host",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://elsewhere.example.com"+challengePath, http.StatusMovedPermanently)
+			},
+			wantErr: "redirected to elsewhere.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			warning, err := checkChallengeURL(server.URL)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantWarning == "" && warning != "" || !strings.Contains(warning, tt.wantWarning) {
+				t.Errorf("warning = %q, want %q", warning, tt.wantWarning)
+			}
+		})
+	}
+}
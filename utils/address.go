@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// Address is a validated NETSOCS address: an IP literal or a host name in
+// its ASCII (IDNA) form, with an optional port.
+type Address struct {
+	// Host is the IP without brackets or the lower-case ASCII host name.
+	Host string
+	// IP is valid when Host is an IP literal.
+	IP   netip.Addr
+	Port uint16
+}
+
+// String returns the address as used in URLs: IPv6 literals in brackets and
+// the port when set.
+func (a Address) String() string {
+	host := a.Host
+	if a.IP.Is6() {
+		host = "[" + host + "]"
+	}
+	if a.Port != 0 {
+		return host + ":" + strconv.Itoa(int(a.Port))
+	}
+	return host
+}
+
+// ParseAddress validates an IPv4 or IPv6 address or a host name, optionally
+// followed by a port ("host:8443", "[2001:db8::1]:8443"). International
+// names are converted to their ASCII form.
+func ParseAddress(input string) (Address, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return Address{}, fmt.Errorf("the address is empty")
+	}
+	if strings.Contains(input, "://") || strings.ContainsAny(input, "/?#@ ") {
+		return Address{}, fmt.Errorf("enter only the host name or IP, without scheme or path")
+	}
+
+	host, portText := input, ""
+	switch {
+	case strings.HasPrefix(input, "["):
+		end := strings.Index(input, "]")
+		if end < 0 {
+			return Address{}, fmt.Errorf("missing ']' after the IPv6 address")
+		}
+		host = input[1:end]
+		rest := input[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return Address{}, fmt.Errorf("unexpected %q after the IPv6 address", rest)
+			}
+			portText = rest[1:]
+		}
+		if _, err := netip.ParseAddr(host); err != nil || !strings.Contains(host, ":") {
+			return Address{}, fmt.Errorf("%q is not a valid IPv6 address", host)
+		}
+	case strings.Count(input, ":") == 1:
+		host, portText, _ = strings.Cut(input, ":")
+	}
+
+	var address Address
+	if portText != "" || strings.HasSuffix(input, ":") {
+		port, err := strconv.ParseUint(portText, 10, 16)
+		if err != nil || port == 0 {
+			return Address{}, fmt.Errorf("invalid port %q, must be between 1 and 65535", portText)
+		}
+		address.Port = uint16(port)
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip.Zone() != "" {
+			return Address{}, fmt.Errorf("IPv6 zones (%%%s) are not supported", ip.Zone())
+		}
+		address.IP = ip.Unmap()
+		address.Host = address.IP.String()
+		return address, nil
+	}
+	if strings.Contains(host, ":") {
+		return Address{}, fmt.Errorf("%q is not a valid IPv6 address; use [address]:port to add a port", host)
+	}
+	if looksLikeIPv4(host) {
+		return Address{}, fmt.Errorf("%q is not a valid IPv4 address (four numbers from 0 to 255)", host)
+	}
+
+	name, err := hostnameToASCII(host)
+	if err != nil {
+		return Address{}, err
+	}
+	// Full-width digits and dots map to an IPv4 address.
+	if ip, err := netip.ParseAddr(name); err == nil {
+		address.IP = ip
+		address.Host = ip.String()
+		return address, nil
+	}
+	if looksLikeIPv4(name) {
+		return Address{}, fmt.Errorf("%q is not a valid IPv4 address (four numbers from 0 to 255)", host)
+	}
+	address.Host = name
+	return address, nil
+}
+
+// Warnings returns the problems of an otherwise valid address.
+func (a Address) Warnings() []string {
+	var warnings []string
+	switch {
+	case a.IP.IsLoopback() || a.Host == "localhost" || strings.HasSuffix(a.Host, ".localhost"):
+		warnings = append(warnings, fmt.Sprintf("%s is a loopback address, NETSOCS will only be reachable from this server", a.Host))
+	case a.IP.IsUnspecified():
+		warnings = append(warnings, fmt.Sprintf("%s is not a reachable address", a.Host))
+	case a.IP.IsLinkLocalUnicast():
+		warnings = append(warnings, fmt.Sprintf("%s is a link-local address, only reachable from the same network segment", a.Host))
+	}
+	return warnings
+}
+
+func looksLikeIPv4(host string) bool {
+	labels := strings.Split(host, ".")
+	last := labels[len(labels)-1]
+	if last == "" {
+		return false
+	}
+	for _, c := range last {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	// A numeric top-level label is never a valid host name.
+	return true
+}
+
+// hostnameToASCII maps a host name with the IDNA lookup rules (lower case,
+// full-width characters and dots), converts international labels to
+// punycode and checks the length and character rules of every label.
+func hostnameToASCII(host string) (string, error) {
+	name, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid host name: %w", host, err)
+	}
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return "", fmt.Errorf("the host name is empty")
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return "", fmt.Errorf("%q has an empty label", host)
+		}
+		if len(label) > 63 {
+			return "", fmt.Errorf("label %q is longer than 63 characters", label)
+		}
+	}
+	if len(name) > 253 {
+		return "", fmt.Errorf("the host name is longer than 253 characters")
+	}
+	return name, nil
+}
+
+// SuggestAddresses returns addresses this server is likely reachable at: its
+// host name, the addresses of its interfaces and their reverse-DNS names.
+// Loopback and link-local addresses are left out.
+func SuggestAddresses() []string {
+	seen := map[string]bool{}
+	var suggestions []string
+	add := func(s string) {
+		s = strings.TrimSuffix(s, ".")
+		if s != "" && !seen[s] {
+			seen[s] = true
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	if name, err := os.Hostname(); err == nil && strings.Contains(name, ".") {
+		add(strings.ToLower(name))
+	}
+
+	addrs, _ := LocalAddresses()
+	var usable []netip.Addr
+	for _, addr := range addrs {
+		if addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+			continue
+		}
+		usable = append(usable, addr)
+	}
+
+	// Reverse lookups can hang on broken resolvers, so bound them.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	names := make([][]string, len(usable))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i, addr := range usable {
+			names[i], _ = net.DefaultResolver.LookupAddr(ctx, addr.String())
+		}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		<-done
+	}
+
+	for i, addr := range usable {
+		for _, name := range names[i] {
+			add(strings.ToLower(name))
+		}
+		add(Address{Host: addr.String(), IP: addr}.String())
+	}
+	return suggestions
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "netsocs.example.com", want: "netsocs.example.com"},
+		{input: "  NETSOCS.Example.COM.  ", want: "netsocs.example.com"},
+		{input: "netsocs.example.com:8443", want: "netsocs.example.com:8443"},
+		{input: "192.168.1.10", want: "192.168.1.10"},
+		{input: "192.168.1.10:443", want: "192.168.1.10:443"},
+		{input: "2001:db8::1", want: "[2001:db8::1]"},
+		{input: "[2001:DB8::1]:8443", want: "[2001:db8::1]:8443"},
+		{input: "::ffff:10.0.0.1", want: "10.0.0.1"},
+		{input: "bücher.example", want: "xn--bcher-kva.example"},
+		{input: "münchen.de:8080", want: "xn--mnchen-3ya.de:8080"},
+		{input: "例え.テスト", want: "xn--r8jz45g.xn--zckzah"},
+		{input: "ＮＥＴＳＯＣＳ．example．com", want: "netsocs.example.com"},
+		{input: "netsocs。example｡com", want: "netsocs.example.com"},
+		{input: "１９２．１６８．１．１０", want: "192.168.1.10"},
+		{input: "", wantErr: "empty"},
+		{input: "https://netsocs.example.com", wantErr: "without scheme"},
+		{input: "netsocs.example.com/path", wantErr: "without scheme"},
+		{input: "user@netsocs.example.com", wantErr: "without scheme"},
+		{input: "netsocs.example.com:0", wantErr: "invalid port"},
+		{input: "netsocs.example.com:65536", wantErr: "invalid port"},
+		{input: "netsocs.example.com:", wantErr: "invalid port"},
+		{input: "[2001:db8::1", wantErr: "missing ']'"},
+		{input: "[2001:db8::1]8443", wantErr: "unexpected"},
+		{input: "[10.0.0.1]", wantErr: "not a valid IPv6"},
+		{input: "2001:db8::zz", wantErr: "not a valid IPv6"},
+		{input: "fe80::1%eth0", wantErr: "zones"},
+		{input: "256.1.1.1", wantErr: "not a valid IPv4"},
+		{input: "10.0.0", wantErr: "not a valid IPv4"},
+		{input: "net..example.com", wantErr: "empty label"},
+		{input: "-netsocs.example.com", wantErr: "not a valid host name"},
+		{input: "netsocs-.example.com", wantErr: "not a valid host name"},
+		{input: "net_socs.example.com", wantErr: "not a valid host name"},
+		{input: "xn--zz.example.com", wantErr: "not a valid host name"},
+		{input: strings.Repeat("a", 64) + ".example.com", wantErr: "longer than 63"},
+		{input: strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com", wantErr: "longer than 253"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			address, err := ParseAddress(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseAddress(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAddress(%q): %v", tt.input, err)
+			}
+			if got := address.String(); got != tt.want {
+				t.Errorf("ParseAddress(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressWarnings(t *testing.T) {
+	tests := map[string]int{
+		"netsocs.example.com": 0,
+		"10.0.0.1":            0,
+		"127.0.0.1":           1,
+		"localhost":           1,
+		"app.localhost":       1,
+		"[::1]":               1,
+		"0.0.0.0":             1,
+		"169.254.10.1":        1,
+	}
+	for input, want := range tests {
+		address, err := ParseAddress(input)
+		if err != nil {
+			t.Fatalf("ParseAddress(%q): %v", input, err)
+		}
+		if got := address.Warnings(); len(got) != want {
+			t.Errorf("%s: warnings = %v", input, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// CLIVersion is the version of the running CLI. main sets it from the
+// embedded version file at startup.
+var CLIVersion = "dev"
+
+// GitCommit and BuildDate are injected at build time with
+//
+//	-ldflags "-X github.com/Netsocs-Team/netsocs-manager-cli/utils.GitCommit=... -X ...utils.BuildDate=..."
+//
+// and fall back to the VCS information Go embeds in the binary.
+var (
+	GitCommit = ""
+	BuildDate = ""
+)
+
+// BuildInfo describes how the running CLI binary was built.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// GetBuildInfo returns the build metadata of the running binary.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   CLIVersion,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.GitCommit == "" {
+					info.GitCommit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
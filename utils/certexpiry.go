@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CheckLevel is the severity of a check, ordered so the worst of several is
+// the largest.
+type CheckLevel int
+
+const (
+	LevelOK CheckLevel = iota
+	LevelWarning
+	LevelUnknown
+	LevelCritical
+)
+
+func (l CheckLevel) String() string {
+	switch l {
+	case LevelOK:
+		return "ok"
+	case LevelWarning:
+		return "warning"
+	case LevelUnknown:
+		return "unknown"
+	default:
+		return "critical"
+	}
+}
+
+func (l CheckLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ExpiryThresholds are the days before expiry at which a certificate is
+// reported as a warning and as critical.
+type ExpiryThresholds struct {
+	WarningDays  int
+	CriticalDays int
+}
+
+// CertificateCheck is the state of one certificate: the one stored in the
+// TLS secret or the one served at httpHostname.
+type CertificateCheck struct {
+	Name       string     `json:"name"`
+	Subject    string     `json:"subject,omitempty"`
+	Issuer     string     `json:"issuer,omitempty"`
+	Hosts      []string   `json:"hosts,omitempty"`
+	NotAfter   *time.Time `json:"notAfter,omitempty"`
+	DaysLeft   *int       `json:"daysLeft,omitempty"`
+	CoversHost *bool      `json:"coversHost,omitempty"`
+	Level      CheckLevel `json:"level"`
+	Message    string     `json:"message"`
+}
+
+// CheckCertificates inspects the TLS secret and the certificate served at
+// address, host[:port] as in httpHostname. The secret is skipped when the
+// ACME resolver is enabled, since Traefik keeps those certificates itself.
+func CheckCertificates(address string, thresholds ExpiryThresholds) []CertificateCheck {
+	var checks []CertificateCheck
+	host := hostOnly(address)
+
+	if !ACMEEnabled() {
+		check := CertificateCheck{Name: "secret " + TLSSecretName()}
+		secret, err := GetTLSSecret()
+		switch {
+		case errors.Is(err, ErrTLSSecretNotFound):
+			// Installs that never ran "cert" rely on the ingress default
+			// certificate; the served check below tells whether it works.
+			check.Level = LevelWarning
+			check.Message = "the TLS secret does not exist; create it with 'netsocs cert generate' or 'netsocs cert import'"
+		case err != nil:
+			check.Level = LevelUnknown
+			check.Message = err.Error()
+		default:
+			check.evaluate(secret.Chain, host, thresholds)
+		}
+		checks = append(checks, check)
+	}
+
+	if host != "" {
+		check := CertificateCheck{Name: "served at " + address}
+		chain, err := ServedCertificate(address)
+		if err != nil {
+			check.Level = LevelCritical
+			check.Message = fmt.Sprintf("no certificate served: %v", err)
+		} else {
+			check.evaluate(chain, host, thresholds)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func (c *CertificateCheck) evaluate(chain []*x509.Certificate, host string, thresholds ExpiryThresholds) {
+	leaf := chain[0]
+	days := DaysUntil(leaf.NotAfter)
+	c.Subject = leaf.Subject.String()
+	c.Issuer = leaf.Issuer.String()
+	c.Hosts = CertificateHosts(leaf)
+	c.NotAfter = &leaf.NotAfter
+	c.DaysLeft = &days
+
+	var problems []string
+	switch {
+	case days < 0:
+		c.Level = LevelCritical
+		problems = append(problems, fmt.Sprintf("expired on %s", leaf.NotAfter.Format("2006-01-02")))
+	case days < thresholds.CriticalDays:
+		c.Level = LevelCritical
+		problems = append(problems, fmt.Sprintf("expires in %d days", days))
+	case days < thresholds.WarningDays:
+		c.Level = LevelWarning
+		problems = append(problems, fmt.Sprintf("expires in %d days", days))
+	}
+	if host != "" {
+		covers := CertificateCovers(leaf, host)
+		c.CoversHost = &covers
+		if !covers {
+			if c.Level < LevelWarning {
+				c.Level = LevelWarning
+			}
+			problems = append(problems, fmt.Sprintf("does not cover %s (covers %s)", host, strings.Join(c.Hosts, ", ")))
+		}
+	}
+
+	if len(problems) == 0 {
+		c.Message = fmt.Sprintf("valid for %d days", days)
+	} else {
+		c.Message = strings.Join(problems, "; ")
+	}
+}
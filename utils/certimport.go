@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ImportedCertificate is a customer-provided certificate ready to be stored
+// in the TLS secret.
+type ImportedCertificate struct {
+	// Chain starts with the server certificate, followed by its issuers.
+	Chain    []*x509.Certificate
+	ChainPEM []byte
+	KeyPEM   []byte
+}
+
+// LoadPEMCertificate reads a certificate chain and its key from PEM files.
+// keyPath may be empty when the key is in the certificate file.
+func LoadPEMCertificate(certPath, keyPath string) (*ImportedCertificate, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := certPEM
+	if keyPath != "" {
+		if keyPEM, err = os.ReadFile(keyPath); err != nil {
+			return nil, err
+		}
+	}
+	return prepareCertificate(certPEM, keyPEM)
+}
+
+// LoadPFXCertificate converts a PKCS#12 (.pfx/.p12) file with openssl, which
+// handles the legacy encryption algorithms most Windows exports still use.
+func LoadPFXCertificate(path, password string) (*ImportedCertificate, error) {
+	run := func(extra ...string) ([]byte, error) {
+		args := append([]string{"pkcs12", "-in", path, "-nodes", "-passin", "env:NETSOCS_PFX_PASSWORD"}, extra...)
+		cmd := exec.Command("openssl", args...)
+		cmd.Env = append(os.Environ(), "NETSOCS_PFX_PASSWORD="+password)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+		}
+		return output, nil
+	}
+
+	if _, err := exec.LookPath("openssl"); err != nil {
+		return nil, fmt.Errorf("openssl is required to read PFX files; convert the file to PEM or install openssl")
+	}
+	output, err := run()
+	if err != nil && strings.Contains(err.Error(), "unsupported") {
+		// OpenSSL 3 needs the legacy provider for RC2/3DES encrypted files.
+		output, err = run("-legacy")
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "mac verify") || strings.Contains(err.Error(), "invalid password") {
+			return nil, fmt.Errorf("wrong PFX password")
+		}
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return prepareCertificate(output, output)
+}
+
+// prepareCertificate checks that the key matches the certificate and orders
+// the chain from the server certificate up to the root.
+func prepareCertificate(certPEM, keyPEM []byte) (*ImportedCertificate, error) {
+	certs, err := ParsePEMCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyBlock := findKeyBlock(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("no private key found")
+	}
+	if keyBlock.Headers["Proc-Type"] != "" {
+		return nil, fmt.Errorf("the private key is encrypted; decrypt it first (openssl pkey -in key.pem -out key-plain.pem)")
+	}
+	keyOut := pem.EncodeToMemory(keyBlock)
+
+	var leaf *x509.Certificate
+	for _, cert := range certs {
+		single := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if _, err := tls.X509KeyPair(single, keyOut); err == nil {
+			leaf = cert
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("the private key does not match any of the certificates")
+	}
+
+	chain := []*x509.Certificate{leaf}
+	used := map[*x509.Certificate]bool{leaf: true}
+	for current := leaf; ; {
+		var issuer *x509.Certificate
+		for _, cert := range certs {
+			if !used[cert] && bytes.Equal(cert.RawSubject, current.RawIssuer) {
+				issuer = cert
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		chain = append(chain, issuer)
+		used[issuer] = true
+		current = issuer
+	}
+
+	var chainPEM []byte
+	for _, cert := range chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return &ImportedCertificate{Chain: chain, ChainPEM: chainPEM, KeyPEM: keyOut}, nil
+}
+
+func findKeyBlock(data []byte) *pem.Block {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			return block
+		}
+	}
+}
+
+// Warnings returns problems that do not prevent the import: a chain that
+// clients cannot verify, a host the certificate does not cover, or a
+// certificate that is expired or not valid yet.
+func (c *ImportedCertificate) Warnings(host string) []string {
+	var warnings []string
+	leaf := c.Chain[0]
+	if host != "" && !CertificateCovers(leaf, host) {
+		warnings = append(warnings, fmt.Sprintf("the certificate does not cover %s (it covers %s)", host, strings.Join(CertificateHosts(leaf), ", ")))
+	}
+	if days := DaysUntil(leaf.NotAfter); days < 0 {
+		warnings = append(warnings, fmt.Sprintf("the certificate expired on %s", leaf.NotAfter.Format("2006-01-02")))
+	} else if days < 30 {
+		warnings = append(warnings, fmt.Sprintf("the certificate expires in %d days", days))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range c.Chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+		warnings = append(warnings, fmt.Sprintf("the chain does not verify against the system roots (%v); include the intermediate certificates", err))
+	}
+	return warnings
+}
+
+// Import stores the certificate in the TLS secret.
+func (c *ImportedCertificate) Import() error {
+	return ApplyTLSSecret(TLSSecretName(), c.ChainPEM, c.KeyPEM, CertSourceImported)
+}
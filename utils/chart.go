@@ -3,8 +3,6 @@ package utils
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/pterm/pterm"
@@ -12,99 +10,92 @@ import (
 )
 
 func UpdateChartConfig(fieldPath string, value interface{}) error {
-	homeDir, err := os.UserHomeDir()
+	return editValuesFile(func(doc *yaml.Node) (bool, error) {
+		pterm.Info.Printfln("Updating field '%s' with value: %v", fieldPath, FormatValue(value))
+		if err := SetNodeValue(doc, fieldPath, value); err != nil {
+			return false, fmt.Errorf("invalid field path: %w", err)
+		}
+		return true, nil
+	})
+}
+
+// UnsetChartConfig removes a field from values.yaml so the chart default
+// applies again.
+func UnsetChartConfig(fieldPath string) error {
+	return editValuesFile(func(doc *yaml.Node) (bool, error) {
+		removed, err := UnsetNodeValue(doc, fieldPath)
+		if err != nil {
+			return false, fmt.Errorf("invalid field path: %w", err)
+		}
+		if !removed {
+			pterm.Warning.Printfln("Field '%s' is not set in values.yaml", fieldPath)
+			return false, nil
+		}
+		pterm.Info.Printfln("Removing field '%s'", fieldPath)
+		return true, nil
+	})
+}
+
+// editValuesFile loads values.yaml as a node tree, applies edit and writes
+// the file back when edit reports a change.
+func editValuesFile(edit func(doc *yaml.Node) (bool, error)) error {
+	valuesPath, err := ValuesFilePath()
 	if err != nil {
-		return fmt.Errorf("could not get home directory: %w", err)
+		return err
 	}
-
-	valuesPath := filepath.Join(homeDir, "netsocs", "values.yaml")
 	pterm.Debug.Printfln("Buscando archivo en: %s", valuesPath)
 
 	if _, err := os.Stat(valuesPath); os.IsNotExist(err) {
 		return fmt.Errorf("the values.yaml file does not exist at %s", valuesPath)
 	}
 
-	yamlFile, err := os.ReadFile(valuesPath)
+	original, err := os.ReadFile(valuesPath)
 	if err != nil {
 		return fmt.Errorf("error reading YAML file: %w", err)
 	}
-
-	var data map[string]interface{}
-	if err := yaml.Unmarshal(yamlFile, &data); err != nil {
-		return fmt.Errorf("error decoding YAML: %w", err)
-	}
-
-	pterm.Info.Printfln("Updating field '%s' with value: %v", fieldPath, value)
-
-	fields := strings.Split(fieldPath, ".")
-	updateNestedField(data, fields, value)
-
-	updatedYaml, err := yaml.Marshal(data)
+	doc, err := parseValuesDocument(original)
 	if err != nil {
-		return fmt.Errorf("error generating YAML: %w", err)
-	}
-
-	if err := os.WriteFile(valuesPath, updatedYaml, 0644); err != nil {
-		return fmt.Errorf("error writing file: %w", err)
-	}
-
-	pterm.Success.Printfln("values.yaml file updated successfully")
-	return nil
-}
-
-func updateNestedField(data map[string]interface{}, fields []string, value interface{}) {
-	currentField := fields[0]
-
-	if len(fields) == 1 {
-		data[currentField] = value
-		return
-	}
-
-	if _, exists := data[currentField]; !exists {
-		data[currentField] = make(map[string]interface{})
+		return err
 	}
 
-	if nestedMap, ok := data[currentField].(map[string]interface{}); ok {
-		updateNestedField(nestedMap, fields[1:], value)
-	} else {
-		newMap := make(map[string]interface{})
-		data[currentField] = newMap
-		updateNestedField(newMap, fields[1:], value)
+	changed, err := edit(doc)
+	if err != nil || !changed {
+		return err
 	}
-}
 
-func RunHelmUpgrade() error {
-	homeDir, err := os.UserHomeDir()
+	updatedYaml, err := EncodeValuesDocument(doc, original)
 	if err != nil {
 		return err
 	}
 
-	valuesPath := filepath.Join(homeDir, "netsocs", "values.yaml")
-	cmd := exec.Command("helm", "upgrade", "netsocs", "netsocs/netsocs-helm-chart", "--values", valuesPath)
-
-	pterm.Info.Printfln("Ejecutando: %s", strings.Join(cmd.Args, " "))
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if err := WriteValuesFile(updatedYaml); err != nil {
+		return err
+	}
 
-	return cmd.Run()
+	pterm.Success.Printfln("values.yaml file updated successfully")
+	return nil
 }
 
+// RunHelmUpgradeWithVersion deploys the values layers with the given chart
+// version, or the latest one when version is empty. Commands that only
+// re-apply values pass DeployedChartVersion so the chart does not move; only
+// "netsocs upgrade" changes it.
 func RunHelmUpgradeWithVersion(version string) error {
-	homeDir, err := os.UserHomeDir()
+	valuesArgs, secrets, err := helmValuesArgs()
 	if err != nil {
 		return err
 	}
 
-	valuesPath := filepath.Join(homeDir, "netsocs", "values.yaml")
-	args := []string{"upgrade", "netsocs", "netsocs/netsocs-helm-chart", "--values", valuesPath}
+	s := CurrentSettings()
+	args := append([]string{"upgrade", s.ReleaseName, s.Chart}, valuesArgs...)
 	if version != "" {
 		args = append(args, "--version", version)
 	}
-	cmd := exec.Command("helm", args...)
+	cmd := HelmCommand(args...)
 
 	pterm.Info.Printfln("Running: %s", strings.Join(cmd.Args, " "))
 
+	cmd.Stdin = secrets
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -112,11 +103,11 @@ func RunHelmUpgradeWithVersion(version string) error {
 }
 
 func RunHelmRollback(revision string) error {
-	args := []string{"rollback", "netsocs"}
+	args := []string{"rollback", CurrentSettings().ReleaseName}
 	if revision != "" {
 		args = append(args, revision)
 	}
-	cmd := exec.Command("helm", args...)
+	cmd := HelmCommand(args...)
 
 	pterm.Info.Printfln("Running: %s", strings.Join(cmd.Args, " "))
 
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	minCLIAnnotation = "netsocs.com/min-cli-version"
+	maxCLIAnnotation = "netsocs.com/max-cli-version"
+)
+
+// CompatStatus is the result of checking a CLI version against the
+// compatibility annotations of a chart version.
+type CompatStatus struct {
+	CLIVersion   string
+	ChartVersion string
+	MinCLI       string
+	MaxCLI       string
+	Compatible   bool
+	Known        bool
+	Reason       string
+}
+
+// CheckCompatibility reads the min/max CLI annotations of chartVersion from
+// the Helm repo and checks cliVersion against them. Charts without
+// annotations are treated as compatible but reported as unknown.
+func CheckCompatibility(cliVersion, chartVersion string) (CompatStatus, error) {
+	annotations, err := chartAnnotations(chartVersion)
+	if err != nil {
+		return CompatStatus{CLIVersion: cliVersion, ChartVersion: chartVersion, Compatible: true}, err
+	}
+	return compatibilityFromAnnotations(cliVersion, chartVersion, annotations), nil
+}
+
+// compatibilityFromAnnotations checks cliVersion against the min/max CLI
+// annotations of a chart.
+func compatibilityFromAnnotations(cliVersion, chartVersion string, annotations map[string]string) CompatStatus {
+	status := CompatStatus{
+		CLIVersion:   cliVersion,
+		ChartVersion: chartVersion,
+		MinCLI:       annotations[minCLIAnnotation],
+		MaxCLI:       annotations[maxCLIAnnotation],
+		Compatible:   true,
+	}
+	status.Known = status.MinCLI != "" || status.MaxCLI != ""
+
+	switch {
+	case status.MinCLI != "" && CompareVersions(cliVersion, status.MinCLI) < 0:
+		status.Compatible = false
+		status.Reason = fmt.Sprintf("chart %s requires CLI %s or newer", displayChartVersion(chartVersion), status.MinCLI)
+	case status.MaxCLI != "" && CompareVersions(cliVersion, status.MaxCLI) > 0:
+		status.Compatible = false
+		status.Reason = fmt.Sprintf("chart %s supports CLI up to %s", displayChartVersion(chartVersion), status.MaxCLI)
+	}
+	return status
+}
+
+// String renders the status for humans, e.g. in the version command.
+func (s CompatStatus) String() string {
+	switch {
+	case !s.Compatible:
+		return "incompatible: " + s.Reason
+	case !s.Known:
+		return "unknown (chart has no CLI compatibility metadata)"
+	default:
+		return fmt.Sprintf("compatible (CLI %s)", s.rangeString())
+	}
+}
+
+func (s CompatStatus) rangeString() string {
+	switch {
+	case s.MinCLI != "" && s.MaxCLI != "":
+		return s.MinCLI + " - " + s.MaxCLI
+	case s.MinCLI != "":
+		return ">= " + s.MinCLI
+	default:
+		return "<= " + s.MaxCLI
+	}
+}
+
+func displayChartVersion(version string) string {
+	if version == "" {
+		return "latest"
+	}
+	return version
+}
+
+// EnsureCompatible checks cliVersion against chartVersion and returns an error
+// for incompatible combinations. With force the error is downgraded to a
+// warning. Metadata that cannot be read only produces a warning.
+func EnsureCompatible(cliVersion, chartVersion string, force bool) error {
+	status, err := CheckCompatibility(cliVersion, chartVersion)
+	if err != nil {
+		pterm.Warning.Printfln("Could not verify CLI/chart compatibility: %v", err)
+		return nil
+	}
+	if status.Compatible {
+		return nil
+	}
+	if force {
+		pterm.Warning.Printfln("Ignoring incompatibility because of --force: %s", status.Reason)
+		return nil
+	}
+	return fmt.Errorf("CLI %s is not compatible with this NETSOCS version: %s (use --force to continue anyway)", cliVersion, status.Reason)
+}
+
+// CheckBeforeUpgrade checks this CLI against the chart version a Helm upgrade
+// is about to deploy. Every command that runs the upgrade calls it before
+// changing anything; force turns an incompatibility into a warning.
+func CheckBeforeUpgrade(chartVersion string, force bool) error {
+	return EnsureCompatible(CLIVersion, chartVersion, force)
+}
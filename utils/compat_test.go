@@ -0,0 +1,39 @@
+package utils
+
+import "testing"
+
+func TestCompatibilityFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name           string
+		cli, chart     string
+		min, max       string
+		wantCompatible bool
+		wantKnown      bool
+		wantReason     string
+	}{
+		{name: "no metadata", cli: "1.0.0", chart: "2.0.0", wantCompatible: true},
+		{name: "inside range", cli: "1.5.0", chart: "2.0.0", min: "1.2.0", max: "1.9.0", wantCompatible: true, wantKnown: true},
+		{name: "equal to min", cli: "1.2.0", chart: "2.0.0", min: "1.2.0", wantCompatible: true, wantKnown: true},
+		{name: "equal to max", cli: "v1.9.0", chart: "2.0.0", max: "1.9.0", wantCompatible: true, wantKnown: true},
+		{name: "below min", cli: "1.1.9", chart: "2.0.0", min: "1.2.0", wantKnown: true, wantReason: "chart 2.0.0 requires CLI 1.2.0 or newer"},
+		{name: "pre-release of min", cli: "1.2.0-rc1", chart: "2.0.0", min: "1.2.0", wantKnown: true, wantReason: "chart 2.0.0 requires CLI 1.2.0 or newer"},
+		{name: "above max", cli: "1.10.0", chart: "2.0.0", max: "1.9.0", wantKnown: true, wantReason: "chart 2.0.0 supports CLI up to 1.9.0"},
+		{name: "latest chart", cli: "1.0.0", chart: "", min: "1.2.0", wantKnown: true, wantReason: "chart latest requires CLI 1.2.0 or newer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tt.min != "" {
+				annotations[minCLIAnnotation] = tt.min
+			}
+			if tt.max != "" {
+				annotations[maxCLIAnnotation] = tt.max
+			}
+			got := compatibilityFromAnnotations(tt.cli, tt.chart, annotations)
+			if got.Compatible != tt.wantCompatible || got.Known != tt.wantKnown || got.Reason != tt.wantReason {
+				t.Errorf("got compatible=%v known=%v reason=%q, want compatible=%v known=%v reason=%q",
+					got.Compatible, got.Known, got.Reason, tt.wantCompatible, tt.wantKnown, tt.wantReason)
+			}
+		})
+	}
+}
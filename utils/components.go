@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ToolVersion is the client and, where it applies, server version of an
+// external tool the CLI depends on.
+type ToolVersion struct {
+	Name   string `json:"name"`
+	Client string `json:"client,omitempty"`
+	Server string `json:"server,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ComponentImage is the image deployed for a NETSOCS component.
+type ComponentImage struct {
+	Component string `json:"component"`
+	Image     string `json:"image"`
+	Tag       string `json:"tag"`
+}
+
+// GetToolVersions returns the versions of Helm, kubectl (and the Kubernetes
+// server it talks to), Kind and Docker. Errors are reported per tool.
+func GetToolVersions() []ToolVersion {
+	return []ToolVersion{
+		helmVersion(),
+		kubectlVersion(),
+		kindVersion(),
+		dockerVersion(),
+	}
+}
+
+func helmVersion() ToolVersion {
+	tool := ToolVersion{Name: "helm"}
+	output, err := HelmCommand("version", "--template", "{{.Version}}").Output()
+	if err != nil {
+		tool.Error = commandError(err)
+		return tool
+	}
+	tool.Client = strings.TrimSpace(string(output))
+	return tool
+}
+
+func kubectlVersion() ToolVersion {
+	tool := ToolVersion{Name: "kubectl"}
+	// kubectl exits non-zero when the server is unreachable but still prints
+	// the client version, so the output is parsed regardless of the error.
+	output, err := KubectlCommand("version", "--output", "json").Output()
+	var data struct {
+		ClientVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"clientVersion"`
+		ServerVersion *struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if jsonErr := json.Unmarshal(output, &data); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		tool.Error = commandError(err)
+		return tool
+	}
+	tool.Client = data.ClientVersion.GitVersion
+	if data.ServerVersion != nil {
+		tool.Server = data.ServerVersion.GitVersion
+	} else if err != nil {
+		tool.Error = "Kubernetes server unreachable"
+	}
+	return tool
+}
+
+func kindVersion() ToolVersion {
+	tool := ToolVersion{Name: "kind"}
+	output, err := exec.Command("kind", "version").Output()
+	if err != nil {
+		tool.Error = commandError(err)
+		return tool
+	}
+	// Output looks like "kind v0.20.0 go1.20.4 linux/amd64".
+	fields := strings.Fields(string(output))
+	if len(fields) >= 2 {
+		tool.Client = fields[1]
+	} else {
+		tool.Client = strings.TrimSpace(string(output))
+	}
+	return tool
+}
+
+func dockerVersion() ToolVersion {
+	tool := ToolVersion{Name: "docker"}
+	output, err := exec.Command("docker", "version", "--format", "{{json .}}").Output()
+	var data struct {
+		Client *struct {
+			Version string `json:"Version"`
+		} `json:"Client"`
+		Server *struct {
+			Version string `json:"Version"`
+		} `json:"Server"`
+	}
+	if jsonErr := json.Unmarshal(output, &data); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		tool.Error = commandError(err)
+		return tool
+	}
+	if data.Client != nil {
+		tool.Client = data.Client.Version
+	}
+	if data.Server != nil {
+		tool.Server = data.Server.Version
+	} else if err != nil {
+		tool.Error = "Docker daemon unreachable"
+	}
+	return tool
+}
+
+// GetComponentImages returns the images of the containers running in the
+// NETSOCS namespace, one entry per component and image.
+func GetComponentImages() ([]ComponentImage, error) {
+	output, err := KubectlCommand("get", "pods", "--output", "json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %s", commandError(err))
+	}
+
+	var data struct {
+		Items []struct {
+			Spec struct {
+				Containers []struct {
+					Name  string `json:"name"`
+					Image string `json:"image"`
+				} `json:"containers"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("error decoding pods: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var images []ComponentImage
+	for _, pod := range data.Items {
+		for _, container := range pod.Spec.Containers {
+			key := container.Name + "|" + container.Image
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			images = append(images, ComponentImage{
+				Component: container.Name,
+				Image:     container.Image,
+				Tag:       imageTag(container.Image),
+			})
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Component < images[j].Component
+	})
+	return images, nil
+}
+
+// imageTag returns the tag or digest of an image reference, "latest" when
+// none is given.
+func imageTag(image string) string {
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		return image[idx+1:]
+	}
+	lastSlash := strings.LastIndex(image, "/")
+	if idx := strings.LastIndex(image, ":"); idx > lastSlash {
+		return image[idx+1:]
+	}
+	return "latest"
+}
+
+// commandError turns an exec error into a short message, including stderr
+// when the command produced any.
+func commandError(err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	numbered := func(from, to int, changed map[int]string) string {
+		var lines []string
+		for i := from; i <= to; i++ {
+			if line, ok := changed[i]; ok {
+				if line != "" {
+					lines = append(lines, line)
+				}
+				continue
+			}
+			lines = append(lines, "line"+string(rune('a'+i-1)))
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "equal", a: "a: 1\n", b: "a: 1\n", want: ""},
+		{
+			name: "created",
+			a:    "",
+			b:    "a: 1\nb: 2\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a: 1\n+b: 2\n",
+		},
+		{
+			name: "emptied",
+			a:    "a: 1\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1,1 +0,0 @@\n-a: 1\n",
+		},
+		{
+			name: "changed line with context",
+			a:    numbered(1, 10, nil),
+			b:    numbered(1, 10, map[int]string{5: "changed"}),
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n lineb\n linec\n lined\n-linee\n+changed\n linef\n lineg\n lineh\n",
+		},
+		{
+			name: "two hunks",
+			a:    numbered(1, 20, nil),
+			b:    numbered(1, 20, map[int]string{2: "", 18: "changed"}),
+			want: "--- old\n+++ new\n@@ -1,5 +1,4 @@\n linea\n-lineb\n linec\n lined\n linee\n" +
+				"@@ -15,6 +14,6 @@\n lineo\n linep\n lineq\n-liner\n+changed\n lines\n linet\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    numbered(1, 12, nil),
+			b:    numbered(1, 12, map[int]string{3: "x", 9: "y"}),
+			want: "--- old\n+++ new\n@@ -1,12 +1,12 @@\n linea\n lineb\n-linec\n+x\n lined\n linee\n linef\n lineg\n lineh\n-linei\n+y\n linej\n linek\n linel\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("old", "new", []byte(tt.a), []byte(tt.b)); got != tt.want {
+				t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffStats(t *testing.T) {
+	diff := UnifiedDiff("old", "new", []byte("---\na: 1\nb: 2\n"), []byte("a: 1\nb: 3\nc: 4\n"))
+	added, removed := DiffStats(diff)
+	if added != 2 || removed != 2 {
+		t.Errorf("DiffStats = +%d -%d, want +2 -2\n%s", added, removed, diff)
+	}
+}
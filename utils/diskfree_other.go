@@ -0,0 +1,10 @@
+//go:build !linux
+
+package utils
+
+import "fmt"
+
+// diskFree is only implemented on Linux, the platform NETSOCS runs on.
+func diskFree(path string) (uint64, error) {
+	return 0, fmt.Errorf("not supported on this platform")
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValuesDrift compares the values deployed by Helm with the local values
+// layers. In Changes, "added" keys only exist locally and "removed" keys only
+// exist in the deployed release.
+type ValuesDrift struct {
+	Deployed map[string]interface{}
+	Local    map[string]interface{}
+	Changes  []ValueChange
+}
+
+// secretMask replaces secret values in drift reports.
+const secretMask = "(secret)"
+
+// DetectDrift compares "helm get values" with the merged values layers.
+func DetectDrift() (*ValuesDrift, error) {
+	deployed, err := GetDeployedValues()
+	if err != nil {
+		return nil, err
+	}
+	local, err := LoadLayeredValues(nil)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := DiffDeployed(deployed, local)
+	if err != nil {
+		return nil, err
+	}
+	return &ValuesDrift{
+		Deployed: deployed,
+		Local:    local,
+		Changes:  changes,
+	}, nil
+}
+
+// DiffDeployed compares deployed values with local ones. Secrets are
+// deployed in plaintext, so they are compared decrypted but their values are
+// never reported. A value counts as a secret when it is encrypted locally or
+// its key looks like a credential, so a secret removed locally is masked too.
+func DiffDeployed(deployed, local map[string]interface{}) ([]ValueChange, error) {
+	decrypted, secretPaths, err := DecryptValues(local)
+	if err != nil {
+		return nil, err
+	}
+	changes := DiffValues(deployed, decrypted)
+	secret := map[string]bool{}
+	for _, path := range secretPaths {
+		secret[path] = true
+	}
+	for i := range changes {
+		if secret[changes[i].Path] || isSecretPath(changes[i].Path) {
+			if changes[i].Old != nil {
+				changes[i].Old = secretMask
+			}
+			if changes[i].New != nil {
+				changes[i].New = secretMask
+			}
+		}
+	}
+	return changes, nil
+}
+
+// AdoptValues rewrites values.yaml so that the merged layers match target,
+// editing only the drifted keys so comments and ordering of everything else
+// are kept. Lists are replaced as a whole since their indexes shift when
+// items change. Keys that another layer still sets are overridden with null,
+// and values that are encrypted locally stay encrypted.
+func AdoptValues(target map[string]interface{}, changes []ValueChange) error {
+	others, err := otherLayersValues()
+	if err != nil {
+		return err
+	}
+	local, err := LoadLayeredValues(nil)
+	if err != nil {
+		return err
+	}
+
+	paths := map[string]bool{}
+	for _, change := range changes {
+		segments, err := ParseValuePath(change.Path)
+		if err != nil {
+			return fmt.Errorf("invalid path %s: %w", change.Path, err)
+		}
+		for i, segment := range segments {
+			if segment.isIndex {
+				segments = segments[:i]
+				break
+			}
+		}
+		paths[joinSegments(segments)] = true
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	// Reverse order removes children before their parents.
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	return editValuesFile(func(doc *yaml.Node) (bool, error) {
+		for _, path := range sorted {
+			value, exists, err := GetValue(target, path)
+			if err != nil {
+				return false, fmt.Errorf("invalid path %s: %w", path, err)
+			}
+			if !exists {
+				if _, err := UnsetNodeValue(doc, path); err != nil {
+					return false, err
+				}
+				if _, setElsewhere, _ := GetValue(others, path); setElsewhere {
+					if err := SetNodeValue(doc, path, nil); err != nil {
+						return false, err
+					}
+				}
+				continue
+			}
+			if value, err = keepEncrypted(local, path, value); err != nil {
+				return false, err
+			}
+			if err := SetNodeValue(doc, path, value); err != nil {
+				return false, err
+			}
+		}
+		return len(sorted) > 0, nil
+	})
+}
+
+// keepEncrypted encrypts the leaves of value at path that are encrypted in
+// the local values.
+func keepEncrypted(local map[string]interface{}, path string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			encrypted, err := keepEncrypted(local, joinPath(path, key), child)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = encrypted
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			encrypted, err := keepEncrypted(local, fmt.Sprintf("%s[%d]", path, i), child)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = encrypted
+		}
+		return result, nil
+	case string:
+		if current, _, _ := GetValue(local, path); IsEncrypted(current) {
+			return EncryptSecret(v)
+		}
+	}
+	return value, nil
+}
+
+// otherLayersValues merges every layer except values.yaml.
+func otherLayersValues() (map[string]interface{}, error) {
+	return LoadLayeredValues(map[string]interface{}{})
+}
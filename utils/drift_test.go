@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffDeployedMasksSecrets(t *testing.T) {
+	useTestSettings(t)
+	encrypted, err := EncryptSecret("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := EncryptSecret("new-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployed := map[string]interface{}{
+		"database": map[string]interface{}{"password": "same", "host": "db1"},
+		"api":      map[string]interface{}{"token": "old-token"},
+		"smtp":     map[string]interface{}{"password": "deleted-locally", "existingSecretName": "smtp"},
+		"custom":   "plaintext-secret",
+	}
+	local := map[string]interface{}{
+		"database": map[string]interface{}{"password": encrypted, "host": "db2"},
+		"api":      map[string]interface{}{"token": changed},
+		"smtp":     map[string]interface{}{"existingSecretName": "smtp-new"},
+		"custom":   changed,
+	}
+	got, err := DiffDeployed(deployed, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ValueChange{
+		{Path: "api.token", Kind: "changed", Old: secretMask, New: secretMask},
+		{Path: "custom", Kind: "changed", Old: secretMask, New: secretMask},
+		{Path: "database.host", Kind: "changed", Old: "db1", New: "db2"},
+		{Path: "smtp.existingSecretName", Kind: "changed", Old: "smtp", New: "smtp-new"},
+		{Path: "smtp.password", Kind: "removed", Old: secretMask},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffDeployed =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestIsSecretPath(t *testing.T) {
+	tests := map[string]bool{
+		"database.password":               true,
+		"auth.apiKey":                     true,
+		"auth.api_key":                    true,
+		"tls.privateKey":                  true,
+		"users[0].password":               true,
+		"users[0]":                        false,
+		"database.existingSecretName":     false,
+		"database.passwordFile":           false,
+		"database.host":                   false,
+		`annotations.netsocs\.com/secret`: true,
+		"invalid..path":                   false,
+	}
+	for path, want := range tests {
+		if got := isSecretPath(path); got != want {
+			t.Errorf("isSecretPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// EnvBool reports whether the environment variable is set to a true value
+// ("1", "true", "yes", ...). Unset or unparsable values are false.
+func EnvBool(name string) bool {
+	value := os.Getenv(name)
+	if value == "yes" || value == "y" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(value)
+	return err == nil && enabled
+}
+
+// StdinIsTerminal reports whether the CLI can prompt the user.
+func StdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
@@ -0,0 +1,359 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FirewallStatus is the host firewall found and the ports it lets in.
+type FirewallStatus struct {
+	// Name is ufw, firewalld or nftables.
+	Name    string
+	Active  bool
+	Summary string
+	// rulesKnown is false when the rules could not be read, usually for
+	// lack of root privileges.
+	rulesKnown   bool
+	defaultAllow bool
+	allowed      []portRange
+	// limited are ports accepted only from some sources or interfaces, or
+	// behind conditions the CLI does not evaluate.
+	limited []portRange
+	// nftChain is the "family table chain" of the nftables input hook.
+	nftChain string
+}
+
+type portRange struct {
+	from, to int
+	// protocol is empty when the rule applies to TCP and UDP.
+	protocol string
+}
+
+func (r portRange) matches(port RequiredPort) bool {
+	return port.Port >= r.from && port.Port <= r.to && (r.protocol == "" || r.protocol == port.Protocol)
+}
+
+// Allows reports whether the firewall lets clients reach port. known is
+// false when the rules could not be read, or when they accept the port only
+// from some sources or interfaces, which may or may not include clients.
+func (f *FirewallStatus) Allows(port RequiredPort) (allowed, known bool) {
+	if !f.rulesKnown {
+		return false, false
+	}
+	if f.defaultAllow {
+		return true, true
+	}
+	for _, r := range f.allowed {
+		if r.matches(port) {
+			return true, true
+		}
+	}
+	if f.limits(port) {
+		return false, false
+	}
+	return false, true
+}
+
+// limits reports whether a rule accepts port only under some condition.
+func (f *FirewallStatus) limits(port RequiredPort) bool {
+	for _, r := range f.limited {
+		if r.matches(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePort parses "8443", "8443/tcp" or "5060/udp".
+func ParsePort(spec string) (RequiredPort, error) {
+	number, protocol, _ := strings.Cut(spec, "/")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return RequiredPort{}, fmt.Errorf("invalid protocol %q in %q, use tcp or udp", protocol, spec)
+	}
+	port, err := strconv.Atoi(number)
+	if err != nil || port < 1 || port > 65535 {
+		return RequiredPort{}, fmt.Errorf("invalid port %q", spec)
+	}
+	return RequiredPort{Port: port, Protocol: protocol, Purpose: "requested"}, nil
+}
+
+// parsePortRanges parses port lists like "80,443", "8000:8100" or
+// "8000-8100".
+func parsePortRanges(spec, protocol string) []portRange {
+	var ranges []portRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, ":")
+		if !isRange {
+			from, to, isRange = strings.Cut(part, "-")
+		}
+		if !isRange {
+			to = from
+		}
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if err1 == nil && err2 == nil {
+			ranges = append(ranges, portRange{from: start, to: end, protocol: protocol})
+		}
+	}
+	return ranges
+}
+
+type commandRunner func(name string, args ...string) *exec.Cmd
+
+// DetectFirewall returns the active host firewall, or nil when none is
+// active. Rules that need root to be read are reported as unknown.
+func DetectFirewall() *FirewallStatus {
+	return detectFirewall(exec.Command)
+}
+
+// DetectFirewallAsRoot is DetectFirewall reading the rules through sudo
+// when needed.
+func DetectFirewallAsRoot() *FirewallStatus {
+	return detectFirewall(privileged)
+}
+
+func detectFirewall(run commandRunner) *FirewallStatus {
+	for _, detect := range []func(commandRunner) *FirewallStatus{detectUFW, detectFirewalld, detectNftables} {
+		if status := detect(run); status != nil && status.Active {
+			return status
+		}
+	}
+	return nil
+}
+
+func detectUFW(run commandRunner) *FirewallStatus {
+	if _, err := exec.LookPath("ufw"); err != nil {
+		return nil
+	}
+	status := &FirewallStatus{Name: "ufw"}
+	output, err := run("ufw", "status", "verbose").Output()
+	if err != nil {
+		// Without root, ufw only tells through its config whether it is on.
+		conf, _ := os.ReadFile("/etc/ufw/ufw.conf")
+		status.Active = regexp.MustCompile(`(?m)^ENABLED=yes`).Match(conf)
+		status.Summary = "run as root to read the rules"
+		return status
+	}
+
+	parseUFWStatus(status, string(output))
+	return status
+}
+
+// parseUFWStatus reads the output of "ufw status verbose". Rules limited
+// to a source ("443/tcp ALLOW IN 10.0.0.5") or an interface
+// ("443/tcp on eth0") are recorded as limited.
+func parseUFWStatus(status *FirewallStatus, text string) {
+	status.Active = strings.Contains(text, "Status: active")
+	status.rulesKnown = true
+	status.defaultAllow = regexp.MustCompile(`Default: allow \(incoming\)`).MatchString(text)
+	columns := regexp.MustCompile(`\s{2,}`)
+	for _, line := range strings.Split(text, "\n") {
+		fields := columns.Split(strings.TrimSpace(line), -1)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "ALLOW") || strings.HasSuffix(fields[1], "OUT") {
+			continue
+		}
+		to := strings.TrimSuffix(fields[0], " (v6)")
+		to, iface, _ := strings.Cut(to, " on ")
+		from := "Anywhere"
+		if len(fields) > 2 {
+			from = strings.TrimSuffix(fields[2], " (v6)")
+		}
+
+		var ranges []portRange
+		if to == "Anywhere" {
+			ranges = []portRange{{from: 1, to: 65535}}
+		} else {
+			spec, protocol, _ := strings.Cut(to, "/")
+			// Application profiles such as "Nginx Full" are not resolved.
+			ranges = parsePortRanges(spec, protocol)
+		}
+		if from != "Anywhere" || iface != "" {
+			status.limited = append(status.limited, ranges...)
+		} else {
+			status.allowed = append(status.allowed, ranges...)
+		}
+	}
+	status.Summary = "default incoming " + map[bool]string{true: "allow", false: "deny"}[status.defaultAllow]
+}
+
+func detectFirewalld(run commandRunner) *FirewallStatus {
+	if _, err := exec.LookPath("firewall-cmd"); err != nil {
+		return nil
+	}
+	status := &FirewallStatus{Name: "firewalld"}
+	output, _ := run("firewall-cmd", "--state").Output()
+	status.Active = strings.TrimSpace(string(output)) == "running"
+	if !status.Active {
+		return status
+	}
+
+	zone, _ := run("firewall-cmd", "--get-default-zone").Output()
+	status.Summary = "zone " + strings.TrimSpace(string(zone))
+	ports, err := run("firewall-cmd", "--list-ports").Output()
+	if err != nil {
+		status.Summary += ", run as root to read the rules"
+		return status
+	}
+	status.rulesKnown = true
+	for _, spec := range strings.Fields(string(ports)) {
+		number, protocol, _ := strings.Cut(spec, "/")
+		status.allowed = append(status.allowed, parsePortRanges(number, protocol)...)
+	}
+	services, _ := run("firewall-cmd", "--list-services").Output()
+	for _, service := range strings.Fields(string(services)) {
+		info, err := run("firewall-cmd", "--info-service="+service).Output()
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(info), "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), "ports:"); ok {
+				for _, spec := range strings.Fields(value) {
+					number, protocol, _ := strings.Cut(spec, "/")
+					status.allowed = append(status.allowed, parsePortRanges(number, protocol)...)
+				}
+			}
+		}
+	}
+	return status
+}
+
+var (
+	nftChainHeader = regexp.MustCompile(`^\s*chain (\S+) \{`)
+	nftTableHeader = regexp.MustCompile(`^\s*table (\S+) (\S+) \{`)
+	nftDport       = regexp.MustCompile(`(tcp|udp|th) dport (\{[^}]*\}|\S+)`)
+	nftJump        = regexp.MustCompile(`\b(?:jump|goto) (\S+)`)
+	// nftLimiting matches the rule conditions that restrict who is accepted.
+	nftLimiting = regexp.MustCompile(`\b(saddr|iifname|iif)\b`)
+	// nftNoise matches statements that do not restrict a rule.
+	nftNoise = regexp.MustCompile(`\bcounter( packets \d+ bytes \d+)?|\bcomment "[^"]*"|# handle \d+`)
+)
+
+// detectNftables looks for an input chain dropping traffic by default and
+// the dport rules accepting traffic in it.
+func detectNftables(run commandRunner) *FirewallStatus {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return nil
+	}
+	output, err := run("nft", "list", "ruleset").Output()
+	if err != nil {
+		// Without root the ruleset cannot be read; assume no firewall
+		// rather than reporting every port as unknown.
+		return nil
+	}
+	return parseNftRuleset(string(output))
+}
+
+// parseNftRuleset reads the output of "nft list ruleset". The accept rules
+// of the input chain are followed into the chains it jumps or goes to; the
+// accepts of a chain reached through a conditional jump, or restricted to
+// some sources or interfaces, are recorded as limited.
+func parseNftRuleset(ruleset string) *FirewallStatus {
+	status := &FirewallStatus{Name: "nftables"}
+	chains := map[string][]string{}
+	var family, table, chain string
+	for _, line := range strings.Split(ruleset, "\n") {
+		if m := nftTableHeader.FindStringSubmatch(line); m != nil {
+			family, table = m[1], m[2]
+			continue
+		}
+		if m := nftChainHeader.FindStringSubmatch(line); m != nil {
+			chain = fmt.Sprintf("%s %s %s", family, table, m[1])
+			chains[chain] = nil
+			continue
+		}
+		if chain == "" {
+			continue
+		}
+		if strings.Contains(line, "hook input") {
+			if strings.Contains(line, "policy drop") && status.nftChain == "" {
+				status.Active = true
+				status.nftChain = chain
+			}
+			continue
+		}
+		chains[chain] = append(chains[chain], strings.TrimSpace(line))
+	}
+	if status.nftChain == "" {
+		return status
+	}
+
+	visited := map[string]bool{}
+	var walk func(chain string, limited bool)
+	walk = func(chain string, limited bool) {
+		if visited[chain] {
+			return
+		}
+		visited[chain] = true
+		prefix := chain[:strings.LastIndex(chain, " ")+1]
+		for _, rule := range chains[chain] {
+			if m := nftJump.FindStringSubmatch(rule); m != nil {
+				condition := strings.TrimSpace(nftNoise.ReplaceAllString(rule[:strings.Index(rule, m[0])], ""))
+				walk(prefix+m[1], limited || condition != "")
+				continue
+			}
+			if !strings.Contains(rule, "accept") {
+				continue
+			}
+			for _, m := range nftDport.FindAllStringSubmatch(rule, -1) {
+				protocol := m[1]
+				if protocol == "th" {
+					protocol = ""
+				}
+				ranges := parsePortRanges(strings.Trim(m[2], "{} "), protocol)
+				if limited || nftLimiting.MatchString(rule) {
+					status.limited = append(status.limited, ranges...)
+				} else {
+					status.allowed = append(status.allowed, ranges...)
+				}
+			}
+		}
+	}
+	walk(status.nftChain, false)
+
+	status.rulesKnown = true
+	status.Summary = "input chain " + status.nftChain + " drops by default"
+	return status
+}
+
+// FirewallCommands returns the commands that open ports in firewall.
+func FirewallCommands(firewall *FirewallStatus, ports []RequiredPort) [][]string {
+	var commands [][]string
+	switch firewall.Name {
+	case "ufw":
+		for _, port := range ports {
+			commands = append(commands, []string{"ufw", "allow", port.String(), "comment", "netsocs"})
+		}
+	case "firewalld":
+		for _, port := range ports {
+			commands = append(commands, []string{"firewall-cmd", "--permanent", "--add-port=" + port.String()})
+		}
+		if len(ports) > 0 {
+			commands = append(commands, []string{"firewall-cmd", "--reload"})
+		}
+	case "nftables":
+		for _, port := range ports {
+			args := append([]string{"nft", "insert", "rule"}, strings.Fields(firewall.nftChain)...)
+			commands = append(commands, append(args, port.Protocol, "dport", strconv.Itoa(port.Port), "accept", "comment", `"netsocs"`))
+		}
+	}
+	return commands
+}
+
+// ApplyFirewall runs the commands of FirewallCommands as root.
+func ApplyFirewall(firewall *FirewallStatus, ports []RequiredPort) error {
+	for _, command := range FirewallCommands(firewall, ports) {
+		cmd := privileged(command[0], command[1:]...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %s", strings.Join(command, " "), strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
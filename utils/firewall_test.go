@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		spec, want, wantErr string
+	}{
+		{spec: "8443", want: "8443/tcp"},
+		{spec: "8443/tcp", want: "8443/tcp"},
+		{spec: "5060/udp", want: "5060/udp"},
+		{spec: "65535", want: "65535/tcp"},
+		{spec: "0", wantErr: "invalid port"},
+		{spec: "65536/tcp", wantErr: "invalid port"},
+		{spec: "http", wantErr: "invalid port"},
+		{spec: "53/sctp", wantErr: "invalid protocol"},
+	}
+	for _, tt := range tests {
+		port, err := ParsePort(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParsePort(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || port.String() != tt.want {
+			t.Errorf("ParsePort(%q) = %v, %v, want %s", tt.spec, port, err, tt.want)
+		}
+	}
+}
+
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		spec, protocol string
+		want           []portRange
+	}{
+		{"443", "tcp", []portRange{{443, 443, "tcp"}}},
+		{"80,443", "tcp", []portRange{{80, 80, "tcp"}, {443, 443, "tcp"}}},
+		{"80, 443", "", []portRange{{80, 80, ""}, {443, 443, ""}}},
+		{"8000:8100", "udp", []portRange{{8000, 8100, "udp"}}},
+		{"8000-8100", "tcp", []portRange{{8000, 8100, "tcp"}}},
+		{"22,8000-8100,http", "tcp", []portRange{{22, 22, "tcp"}, {8000, 8100, "tcp"}}},
+		{"Nginx Full", "", nil},
+	}
+	for _, tt := range tests {
+		if got := parsePortRanges(tt.spec, tt.protocol); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePortRanges(%q, %q) = %v, want %v", tt.spec, tt.protocol, got, tt.want)
+		}
+	}
+}
+
+// firewallVerdict describes how firewall treats port: allowed, blocked or
+// unknown.
+func firewallVerdict(firewall *FirewallStatus, spec string) string {
+	port, _ := ParsePort(spec)
+	allowed, known := firewall.Allows(port)
+	switch {
+	case !known:
+		return "unknown"
+	case allowed:
+		return "allowed"
+	}
+	return "blocked"
+}
+
+func TestParseUFWStatus(t *testing.T) {
+	const output = `Status: active
+Logging: on (low)
+Default: deny (incoming), allow (outgoing), disabled (routed)
+New profiles: skip
+
+To                         Action      From
+--                         ----        ----
+22/tcp                     ALLOW IN    Anywhere
+80,443/tcp                 ALLOW IN    Anywhere
+6443/tcp                   ALLOW IN    10.0.0.5
+8443/tcp on eth1           ALLOW IN    Anywhere
+9000:9100/udp              ALLOW IN    Anywhere
+5432                       DENY IN     Anywhere
+25/tcp                     ALLOW OUT   Anywhere
+Anywhere                   ALLOW IN    192.168.10.0/24
+22/tcp (v6)                ALLOW IN    Anywhere (v6)
+`
+	status := &FirewallStatus{Name: "ufw"}
+	parseUFWStatus(status, output)
+	if !status.Active || status.defaultAllow {
+		t.Fatalf("Active = %v, defaultAllow = %v", status.Active, status.defaultAllow)
+	}
+	tests := map[string]string{
+		"22/tcp":   "allowed",
+		"80/tcp":   "allowed",
+		"443/tcp":  "allowed",
+		"443/udp":  "unknown",
+		"6443/tcp": "unknown",
+		"8443/tcp": "unknown",
+		"9050/udp": "allowed",
+		"9050/tcp": "unknown",
+		"25/tcp":   "unknown",
+	}
+	for spec, want := range tests {
+		if got := firewallVerdict(status, spec); got != want {
+			t.Errorf("%s: %s, want %s", spec, got, want)
+		}
+	}
+
+	status = &FirewallStatus{Name: "ufw"}
+	parseUFWStatus(status, "Status: active\nDefault: deny (incoming), allow (outgoing)\n\n22/tcp  ALLOW IN  Anywhere\n")
+	if got := firewallVerdict(status, "443/tcp"); got != "blocked" {
+		t.Errorf("443/tcp without a rule: %s", got)
+	}
+}
+
+func TestParseNftRuleset(t *testing.T) {
+	const ruleset = `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		ct state established,related accept
+		iifname "lo" accept
+		tcp dport 22 counter packets 10 bytes 600 accept
+		jump services
+		ip saddr 10.0.0.0/8 tcp dport 6443 accept
+		tcp dport 8443 jump admin
+		iifname "eth1" goto internal
+	}
+
+	chain services {
+		tcp dport { 80, 443 } accept comment "web"
+		udp dport 9000-9100 accept
+		jump services
+	}
+
+	chain admin {
+		tcp dport 8443 accept
+	}
+
+	chain internal {
+		th dport 5000 accept
+	}
+
+	chain unused {
+		tcp dport 3306 accept
+	}
+
+	chain forward {
+		type filter hook forward priority filter; policy accept;
+	}
+}
+`
+	status := parseNftRuleset(ruleset)
+	if !status.Active || status.nftChain != "inet filter input" {
+		t.Fatalf("Active = %v, chain = %q", status.Active, status.nftChain)
+	}
+	tests := map[string]string{
+		"22/tcp":   "allowed",
+		"80/tcp":   "allowed",
+		"443/tcp":  "allowed",
+		"9050/udp": "allowed",
+		"6443/tcp": "unknown",
+		"8443/tcp": "unknown",
+		"5000/udp": "unknown",
+		"3306/tcp": "blocked",
+		"25/tcp":   "blocked",
+	}
+	for spec, want := range tests {
+		if got := firewallVerdict(status, spec); got != want {
+			t.Errorf("%s: %s, want %s", spec, got, want)
+		}
+	}
+
+	open := parseNftRuleset("table inet filter {\n\tchain input {\n\t\ttype filter hook input priority filter; policy accept;\n\t}\n}\n")
+	if open.Active {
+		t.Error("an input chain accepting by default is reported as a firewall")
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// secretValuesAnnotation lists, comma separated, the value paths the
+	// chart wants filled with random secrets.
+	secretValuesAnnotation = "netsocs.com/secret-values"
+	generatedSecretLength  = 32
+	// Letters and digits only, so the values are safe in URLs, connection
+	// strings and shell scripts.
+	generatedSecretAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var (
+	secretKeyRegex = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[-_]?key|private[-_]?key)$`)
+	// Keys that name a secret rather than hold one.
+	secretRefRegex = regexp.MustCompile(`(?i)(existing|name|ref|file|path|key[-_]?id)`)
+)
+
+// SecretValuePaths returns the paths of values to fill with random secrets:
+// the ones listed in the chart annotation, plus the string leaves whose key
+// looks like a credential and that carry a default value shared by every
+// install.
+func SecretValuePaths(values map[string]interface{}, annotations map[string]string) []string {
+	paths := map[string]bool{}
+	for _, path := range strings.Split(annotations[secretValuesAnnotation], ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths[path] = true
+		}
+	}
+
+	for path, value := range FlattenValues(values) {
+		str, ok := value.(string)
+		if !ok || str == "" || IsEncrypted(str) {
+			continue
+		}
+		if isSecretPath(path) {
+			paths[path] = true
+		}
+	}
+
+	result := make([]string, 0, len(paths))
+	for path := range paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// isSecretPath reports whether the last key of path looks like a credential,
+// e.g. "database.password" but not "database.existingSecretName".
+func isSecretPath(path string) bool {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return false
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		if !segments[i].isIndex {
+			key := segments[i].key
+			return secretKeyRegex.MatchString(key) && !secretRefRegex.MatchString(key)
+		}
+	}
+	return false
+}
+
+// GenerateSecrets fills the given paths of the document with random values,
+// stored encrypted. It returns the paths that were filled.
+func GenerateSecrets(doc *yaml.Node, paths []string) ([]string, error) {
+	var generated []string
+	for _, path := range paths {
+		value, err := randomSecret(generatedSecretLength)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := EncryptSecret(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := SetNodeValue(doc, path, encrypted); err != nil {
+			return nil, fmt.Errorf("error setting %s: %w", path, err)
+		}
+		generated = append(generated, path)
+	}
+	return generated, nil
+}
+
+// PrintGeneratedSecrets prints the one-time summary of generated
+// credentials. The values themselves are never shown.
+func PrintGeneratedSecrets(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	pterm.DefaultSection.Println("Generated credentials")
+	tableData := pterm.TableData{{"Key"}}
+	for _, path := range paths {
+		tableData = append(tableData, []string{path})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	pterm.Info.Printfln("These %d value(s) replace the chart defaults with random secrets, stored encrypted in values.yaml.", len(paths))
+	pterm.Info.Printfln("Back up the key %s: without it they cannot be decrypted.", CurrentSettings().SecretKeyFile)
+}
+
+func randomSecret(length int) (string, error) {
+	max := big.NewInt(int64(len(generatedSecretAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("error generating secret: %w", err)
+		}
+		b[i] = generatedSecretAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -2,33 +2,45 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
 )
 
-const (
-	HelmRepoURL = "https://netsocs-team.github.io/netsocs-helm-chart/"
-	AppName     = "netsocs"
-)
+const HelmRepoURL = "https://netsocs-team.github.io/netsocs-helm-chart/"
 
-type helmRelease struct {
-	Name  string `json:"name"`
-	Chart string `json:"chart"`
+// HelmRelease is an entry of "helm list --output json".
+type HelmRelease struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Revision   string `json:"revision"`
+	Status     string `json:"status"`
+	Chart      string `json:"chart"`
+	AppVersion string `json:"app_version"`
 }
 
 type helmChartVersion struct {
 	Version string `json:"version"`
 }
 
-func InitializeHelmSetup() error {
+// InitOptions tunes the first installation done by InitializeHelmSetup.
+type InitOptions struct {
+	SkipValidation bool
+	// KeepDefaultSecrets keeps the chart's default credentials instead of
+	// generating random ones for a new values.yaml.
+	KeepDefaultSecrets bool
+}
+
+func InitializeHelmSetup(options InitOptions) error {
 	pterm.Info.Println("Initializing Helm configuration for Netsocs...")
 
 	if err := checkHelmInstalled(); err != nil {
@@ -49,11 +61,21 @@ func InitializeHelmSetup() error {
 		return nil
 	}
 
-	valuesExists, err := checkValuesFileOrCreate()
+	valuesExists, err := checkValuesFileOrCreate(!options.KeepDefaultSecrets)
 	if err != nil {
 		return fmt.Errorf("error checking/creating values.yaml file: %w", err)
 	}
 
+	if valuesExists {
+		values, err := LoadLayeredValues(nil)
+		if err != nil {
+			return err
+		}
+		if err := ValidateBeforeApply(values, "", options.SkipValidation); err != nil {
+			return err
+		}
+	}
+
 	if err := installNetsocsApp(valuesExists); err != nil {
 		return fmt.Errorf("error installing application: %w", err)
 	}
@@ -65,7 +87,7 @@ func InitializeHelmSetup() error {
 func checkHelmInstalled() error {
 	pterm.Info.Println("Checking Helm installation...")
 
-	cmd := exec.Command("helm", "version")
+	cmd := HelmCommand("version")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("Helm is not installed or not accessible. Please install Helm first")
 	}
@@ -77,15 +99,22 @@ func checkHelmInstalled() error {
 func addHelmRepo() error {
 	pterm.Info.Println("Adding Helm repository...")
 
-	cmd := exec.Command("helm", "repo", "list")
+	repoName := CurrentSettings().RepoName()
+	cmd := HelmCommand("repo", "list", "--output", "json")
 	output, _ := cmd.Output()
 
-	if strings.Contains(string(output), "netsocs") {
-		pterm.Info.Println("netsocs repository already exists")
-		return nil
+	var repos []struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(output, &repos)
+	for _, repo := range repos {
+		if repo.Name == repoName {
+			pterm.Info.Printfln("%s repository already exists", repoName)
+			return nil
+		}
 	}
 
-	cmd = exec.Command("helm", "repo", "add", "netsocs", HelmRepoURL)
+	cmd = HelmCommand("repo", "add", repoName, HelmRepoURL)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -93,7 +122,7 @@ func addHelmRepo() error {
 		return fmt.Errorf("error adding repository: %w", err)
 	}
 
-	cmd = exec.Command("helm", "repo", "update")
+	cmd = HelmCommand("repo", "update")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -106,40 +135,44 @@ func addHelmRepo() error {
 }
 
 func checkIfAppInstalled() (bool, error) {
-	pterm.Info.Println("Checking if netsocs is installed...")
+	pterm.Info.Printfln("Checking if release %s is installed...", CurrentSettings().ReleaseName)
 
-	cmd := exec.Command("helm", "list", "--output", "json")
-	output, err := cmd.Output()
+	_, err := GetCurrentRelease()
+	if errors.Is(err, ErrNotInstalled) {
+		return false, nil
+	}
 	if err != nil {
 		return false, fmt.Errorf("error listing Helm applications: %w", err)
 	}
-
-	return strings.Contains(string(output), AppName), nil
+	return true, nil
 }
 
-func checkValuesFileOrCreate() (bool, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return false, fmt.Errorf("error getting home directory: %w", err)
-	}
-
-	valuesDir := filepath.Join(homeDir, "netsocs")
-	valuesPath := filepath.Join(valuesDir, "values.yaml")
+func checkValuesFileOrCreate(generateSecrets bool) (bool, error) {
+	s := CurrentSettings()
+	valuesPath := s.ValuesFile
+	valuesDir := filepath.Dir(valuesPath)
 
 	if _, err := os.Stat(valuesPath); os.IsNotExist(err) {
-		pterm.Warning.Println("values.yaml file not found at ~/netsocs/values.yaml. Creating default file...")
+		pterm.Warning.Printfln("values.yaml file not found at %s. Creating default file...", valuesPath)
 		if err := os.MkdirAll(valuesDir, 0755); err != nil {
-			return false, fmt.Errorf("error creating ~/netsocs directory: %w", err)
+			return false, fmt.Errorf("error creating %s directory: %w", valuesDir, err)
 		}
-		cmd := exec.Command("helm", "show", "values", "netsocs/netsocs-helm-chart ")
+		cmd := HelmCommand("show", "values", s.Chart)
 		output, err := cmd.Output()
 		if err != nil {
 			return false, fmt.Errorf("error getting default Helm values: %w", err)
 		}
-		if err := os.WriteFile(valuesPath, output, 0644); err != nil {
+		var generated []string
+		if generateSecrets {
+			if output, generated, err = withGeneratedSecrets(output); err != nil {
+				return false, err
+			}
+		}
+		if err := WriteValuesFile(output); err != nil {
 			return false, fmt.Errorf("error writing values.yaml: %w", err)
 		}
-		pterm.Success.Println("values.yaml file created successfully at ~/netsocs/values.yaml")
+		pterm.Success.Printfln("values.yaml file created successfully at %s", valuesPath)
+		PrintGeneratedSecrets(generated)
 		return true, nil
 	}
 
@@ -147,23 +180,56 @@ func checkValuesFileOrCreate() (bool, error) {
 	return true, nil
 }
 
+// withGeneratedSecrets replaces the default credentials of the chart values
+// with random encrypted ones.
+func withGeneratedSecrets(defaults []byte) ([]byte, []string, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(defaults, &values); err != nil {
+		return nil, nil, fmt.Errorf("error decoding default Helm values: %w", err)
+	}
+	annotations, err := chartAnnotations("")
+	if err != nil {
+		pterm.Debug.Printfln("Chart annotations unavailable: %v", err)
+	}
+	paths := SecretValuePaths(values, annotations)
+	if len(paths) == 0 {
+		return defaults, nil, nil
+	}
+
+	doc, err := parseValuesDocument(defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	generated, err := GenerateSecrets(doc, paths)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating secrets: %w", err)
+	}
+	content, err := EncodeValuesDocument(doc, defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, generated, nil
+}
+
 func installNetsocsApp(hasValuesFile bool) error {
 	pterm.Info.Println("Installing netsocs application...")
 
-	var cmd *exec.Cmd
-
+	s := CurrentSettings()
+	args := []string{"install", s.ReleaseName, s.Chart}
+	if s.Namespace != "" {
+		args = append(args, "--create-namespace")
+	}
+	var secrets io.Reader
 	if hasValuesFile {
-		homeDir, err := os.UserHomeDir()
+		valuesArgs, overlay, err := helmValuesArgs()
 		if err != nil {
-			return fmt.Errorf("error getting home directory: %w", err)
+			return err
 		}
-		valuesPath := filepath.Join(homeDir, "netsocs", "values.yaml")
-
-		cmd = exec.Command("helm", "install", AppName, "netsocs/netsocs-helm-chart ",
-			"--values", valuesPath)
-	} else {
-		cmd = exec.Command("helm", "install", AppName, "netsocs/netsocs-helm-chart ")
+		args = append(args, valuesArgs...)
+		secrets = overlay
 	}
+	cmd := HelmCommand(args...)
+	cmd.Stdin = secrets
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -176,26 +242,42 @@ func installNetsocsApp(hasValuesFile bool) error {
 	return nil
 }
 
-func GetCurrentAppVersion() string {
-	cmd := exec.Command("helm", "list", "--output", "json")
+// ErrNotInstalled is returned when the configured release does not exist.
+var ErrNotInstalled = errors.New("netsocs is not installed")
+
+// GetCurrentRelease returns the deployed netsocs release as reported by
+// "helm list".
+func GetCurrentRelease() (*HelmRelease, error) {
+	cmd := HelmCommand("list", "--output", "json")
 	output, err := cmd.Output()
 	if err != nil {
-		return "unknown"
+		return nil, fmt.Errorf("error listing Helm releases: %w", err)
 	}
-	var releases []helmRelease
+	var releases []HelmRelease
 	if err := json.Unmarshal(output, &releases); err != nil {
-		return "unknown"
+		return nil, fmt.Errorf("error decoding Helm releases: %w", err)
 	}
 	for _, rel := range releases {
-		if rel.Name == AppName {
-			return rel.Chart
+		if rel.Name == CurrentSettings().ReleaseName {
+			return &rel, nil
 		}
 	}
-	return "not installed"
+	return nil, ErrNotInstalled
+}
+
+func GetCurrentAppVersion() string {
+	rel, err := GetCurrentRelease()
+	if errors.Is(err, ErrNotInstalled) {
+		return "not installed"
+	}
+	if err != nil {
+		return "unknown"
+	}
+	return rel.Chart
 }
 
 func ListAvailableAppVersions() ([]string, error) {
-	cmd := exec.Command("helm", "search", "repo", "netsocs/netsocs-helm-chart", "--versions", "--output", "json")
+	cmd := HelmCommand("search", "repo", CurrentSettings().Chart, "--versions", "--output", "json")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -214,145 +296,101 @@ func ListAvailableAppVersions() ([]string, error) {
 	return result, nil
 }
 
-func ListAvailableCLIVersions() ([]string, error) {
-	const repo = "Netsocs-Team/netsocs-cli"
-	resp, err := http.Get("https://api.github.com/repos/" + repo + "/releases")
+// ChartIndexEntry is a chart version listed in the index.yaml of a Helm
+// repository.
+type ChartIndexEntry struct {
+	Version     string            `yaml:"version"`
+	AppVersion  string            `yaml:"appVersion"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// ChartRepoURL returns the URL of the repository of the configured chart, as
+// registered in Helm, or HelmRepoURL when it is not registered.
+func ChartRepoURL() string {
+	output, err := HelmCommand("repo", "list", "--output", "json").Output()
 	if err != nil {
-		return nil, err
+		return HelmRepoURL
 	}
-	defer resp.Body.Close()
-	var data []struct {
-		TagName string `json:"tag_name"`
+	var repos []struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, err
-	}
-	var result []string
-	for i, rel := range data {
-		if i >= 10 {
-			break
+	json.Unmarshal(output, &repos)
+	for _, repo := range repos {
+		if repo.Name == CurrentSettings().RepoName() {
+			return repo.URL
 		}
-		result = append(result, rel.TagName)
 	}
-	return result, nil
+	return HelmRepoURL
 }
 
-func DownloadAndReplaceCLI(version string) error {
-	const repo = "Netsocs-Team/netsocs-cli"
-	var downloadURL string
-	if version == "" {
-		// Get latest release info
-		resp, err := http.Get("https://api.github.com/repos/" + repo + "/releases/latest")
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		var data struct {
-			TagName string `json:"tag_name"`
-			Assets  []struct {
-				Name               string `json:"name"`
-				BrowserDownloadURL string `json:"browser_download_url"`
-			}
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-			return err
-		}
-		for _, asset := range data.Assets {
-			if asset.Name == "netsocs" {
-				downloadURL = asset.BrowserDownloadURL
-				break
-			}
-		}
-		if downloadURL == "" {
-			return fmt.Errorf("no suitable binary found in latest release")
-		}
-	} else {
-		// Specific version
-		resp, err := http.Get("https://api.github.com/repos/" + repo + "/releases/tags/" + version)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		var data struct {
-			Assets []struct {
-				BrowserDownloadURL string `json:"browser_download_url"`
-				Name               string `json:"name"`
-			}
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-			return err
-		}
-		for _, asset := range data.Assets {
-			if asset.Name == "netsocs" {
-				downloadURL = asset.BrowserDownloadURL
-				break
-			}
-		}
-		if downloadURL == "" {
-			return fmt.Errorf("no suitable binary found for version %s", version)
-		}
-	}
-
-	// Download binary to $HOME/netsocs/netsocs.new
-	homeDir, err := os.UserHomeDir()
+// FetchChartIndex downloads the index.yaml of the chart repository and
+// returns the versions of the configured chart, newest first. Unlike "helm
+// search repo" it does not depend on the last "helm repo update".
+func FetchChartIndex(repoURL string) ([]ChartIndexEntry, error) {
+	indexURL := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	resp, err := NewHTTPClient(10 * time.Second).Get(indexURL)
 	if err != nil {
-		return err
-	}
-	netsocsDir := filepath.Join(homeDir, "netsocs")
-	if err := os.MkdirAll(netsocsDir, 0755); err != nil {
-		return err
-	}
-	newBinPath := filepath.Join(netsocsDir, "netsocs.new")
-	resp, err := http.Get(downloadURL)
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("error fetching %s: %w", indexURL, err)
 	}
 	defer resp.Body.Close()
-	f, err := os.OpenFile(newBinPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
-	if err != nil {
-		return err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", indexURL, resp.Status)
 	}
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		f.Close()
-		return err
+	var index struct {
+		Entries map[string][]ChartIndexEntry `yaml:"entries"`
 	}
-	f.Close()
-
-	// Create update.sh script
-	updateScriptPath := filepath.Join(netsocsDir, "update.sh")
-	updateScript := fmt.Sprintf(`#!/bin/bash
-set -e
-echo "Updating CLI..."
-sudo rm /usr/local/bin/netsocs
-sudo cp "%s" /usr/local/bin/netsocs
-sudo chmod +x /usr/local/bin/netsocs
-echo "Update complete!"
-`, newBinPath)
-	if err := os.WriteFile(updateScriptPath, []byte(updateScript), 0755); err != nil {
-		return err
+	if err := yaml.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", indexURL, err)
 	}
+	entries := index.Entries[CurrentSettings().ChartName()]
+	sort.SliceStable(entries, func(i, j int) bool {
+		return CompareVersions(entries[i].Version, entries[j].Version) > 0
+	})
+	return entries, nil
+}
 
-	pterm.Info.Println("Nuevo binario descargado en:", newBinPath)
-	pterm.Info.Println("Ejecutando script de actualización en segundo plano:", updateScriptPath)
-
-	// Ejecutar el script de forma asíncrona
-	cmd := exec.Command("bash", updateScriptPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	// Iniciar el comando en segundo plano sin esperar
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("error iniciando el script de actualización: %w", err)
+// LatestChartRelease returns the newest version of entries that is not a
+// pre-release, like "helm search repo" does without --devel.
+func LatestChartRelease(entries []ChartIndexEntry) (ChartIndexEntry, bool) {
+	for _, entry := range entries {
+		if _, pre := splitPreRelease(NormalizeVersion(entry.Version)); pre == "" {
+			return entry, true
+		}
 	}
+	return ChartIndexEntry{}, false
+}
 
-	// No esperar a que termine, solo informar que se inició
-	pterm.Success.Println("Actualización iniciada en segundo plano. El CLI se actualizará automáticamente.")
+// ChartVersionFromRelease extracts the chart version from the chart column of
+// "helm list", e.g. "netsocs-helm-chart-1.0.1" -> "1.0.1". It returns an
+// empty string when the release is unknown or not installed.
+func ChartVersionFromRelease(chart string) string {
+	prefix := CurrentSettings().ChartName() + "-"
+	if !strings.HasPrefix(chart, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(chart, prefix)
+}
 
-	return nil
+// DeployedChartVersion returns the chart version of the deployed release, or
+// an empty string when it cannot be determined.
+func DeployedChartVersion() string {
+	return ChartVersionFromRelease(GetCurrentAppVersion())
 }
 
-func isCurrentPlatformAsset(name string) bool {
-	osName := runtime.GOOS
-	arch := runtime.GOARCH
-	return strings.Contains(name, osName) && strings.Contains(name, arch)
+// GetDeployedValues returns the user-supplied values of the deployed release
+// as reported by "helm get values".
+func GetDeployedValues() (map[string]interface{}, error) {
+	output, err := HelmCommand("get", "values", CurrentSettings().ReleaseName, "--output", "yaml").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployed values: %s", commandError(err))
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(output, &values); err != nil {
+		return nil, fmt.Errorf("error decoding deployed values: %w", err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return values, nil
 }
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testChartIndex = `apiVersion: v1
+entries:
+  netsocs-helm-chart:
+  - version: 1.2.0
+    annotations:
+      netsocs.com/security-release: "true"
+  - version: 1.10.0-rc1
+  - version: 1.9.3
+  other-chart:
+  - version: 9.9.9
+`
+
+func TestFetchChartIndex(t *testing.T) {
+	useTestSettings(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testChartIndex))
+	}))
+	defer server.Close()
+
+	entries, err := FetchChartIndex(server.URL + "/charts/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		versions = append(versions, entry.Version)
+	}
+	if got, want := strings.Join(versions, " "), "1.10.0-rc1 1.9.3 1.2.0"; got != want {
+		t.Errorf("versions = %q, want %q", got, want)
+	}
+
+	latest, ok := LatestChartRelease(entries)
+	if !ok || latest.Version != "1.9.3" || latest.Annotations[securityAnnotation] == "true" {
+		t.Errorf("LatestChartRelease = %+v, %v", latest, ok)
+	}
+
+	if _, err := FetchChartIndex(server.URL + "/missing"); err == nil {
+		t.Error("expected an error for a missing index")
+	}
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// ValuesSnapshot is a version of values.yaml recorded by the CLI. Snapshots
+// are independent from Helm revisions so that changes made while offline, or
+// by hand between CLI runs, are also kept.
+type ValuesSnapshot struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	Command   string    `json:"command"`
+	Diff      string    `json:"diff"`
+	Content   string    `json:"content"`
+}
+
+func historyDir() (string, error) {
+	return filepath.Join(CurrentSettings().Home, "history"), nil
+}
+
+// WriteValuesFile atomically replaces values.yaml and records the change in
+// the history. Manual edits made since the last snapshot are recorded first
+// so that they can be restored as well.
+func WriteValuesFile(content []byte) error {
+	valuesPath, err := ValuesFilePath()
+	if err != nil {
+		return err
+	}
+	previous, err := os.ReadFile(valuesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %w", valuesPath, err)
+	}
+
+	if err := recordManualEdits(previous); err != nil {
+		pterm.Warning.Printfln("Could not record manual edits in history: %v", err)
+	}
+
+	if err := writeFileAtomic(valuesPath, content, 0644); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	if string(previous) != string(content) {
+		if _, err := recordSnapshot(previous, content, commandLine()); err != nil {
+			pterm.Warning.Printfln("Could not record values.yaml history: %v", err)
+		}
+	}
+	return nil
+}
+
+// ListValuesSnapshots returns the recorded snapshots, oldest first.
+func ListValuesSnapshots() ([]ValuesSnapshot, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []ValuesSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var snapshot ValuesSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %w", entry.Name(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// GetValuesSnapshot returns the snapshot with the given id.
+func GetValuesSnapshot(id string) (*ValuesSnapshot, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot id %q", id)
+	}
+	snapshots, err := ListValuesSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == n {
+			return &snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot %d not found", n)
+}
+
+// RestoreValuesSnapshot writes the content of a snapshot back to values.yaml.
+// The restore itself is recorded as a new snapshot.
+func RestoreValuesSnapshot(id string) (*ValuesSnapshot, error) {
+	snapshot, err := GetValuesSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parseValuesDocument([]byte(snapshot.Content)); err != nil {
+		return nil, fmt.Errorf("snapshot %d is not valid YAML: %w", snapshot.ID, err)
+	}
+	if err := WriteValuesFile([]byte(snapshot.Content)); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// recordManualEdits snapshots the current file when it no longer matches the
+// latest snapshot, i.e. it was changed outside the CLI. The first time the
+// history is used the existing file is recorded as the initial state.
+func recordManualEdits(current []byte) error {
+	if current == nil {
+		return nil
+	}
+	snapshots, err := ListValuesSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		_, err = recordSnapshot(nil, current, "(initial state)")
+		return err
+	}
+	last := snapshots[len(snapshots)-1]
+	if last.Content == string(current) {
+		return nil
+	}
+	_, err = recordSnapshot([]byte(last.Content), current, "(changes made outside the CLI)")
+	return err
+}
+
+func recordSnapshot(previous, content []byte, command string) (*ValuesSnapshot, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	snapshots, err := ListValuesSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := ValuesSnapshot{
+		ID:        1,
+		Timestamp: time.Now(),
+		Author:    currentAuthor(),
+		Command:   command,
+		Diff:      UnifiedDiff("values.yaml (before)", "values.yaml (after)", previous, content),
+		Content:   string(content),
+	}
+	if len(snapshots) > 0 {
+		snapshot.ID = snapshots[len(snapshots)-1].ID + 1
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// Snapshots may contain credentials, keep them private to the owner.
+	path := filepath.Join(dir, fmt.Sprintf("%06d.json", snapshot.ID))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func currentAuthor() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		return fmt.Sprintf("%s (sudo as %s)", sudoUser, name)
+	}
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// invokedCommand is the command recorded in history snapshots.
+var invokedCommand []string
+
+// SetInvokedCommand records the subcommand names, e.g. ["config", "set"],
+// and the names of the flags given. Arguments and flag values are never
+// recorded, since commands like "config secret set" take secrets.
+func SetInvokedCommand(names, flags []string) {
+	invokedCommand = append([]string{}, names...)
+	for _, flag := range flags {
+		invokedCommand = append(invokedCommand, "--"+flag)
+	}
+}
+
+func commandLine() string {
+	return strings.Join(append([]string{filepath.Base(os.Args[0])}, invokedCommand...), " ")
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and
+// renames it over path, so readers never see a partially written file.
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// HostnameValuePath is the chart value holding the public URL of NETSOCS.
+const HostnameValuePath = "httpHostname"
+
+// HostnameChange is the set of value changes needed to move NETSOCS to a new
+// host name: httpHostname plus the values derived from it, such as CORS
+// origins, ingress hosts and certificate names. Other values mentioning the
+// old host are only suggested, since the same name or IP may also be that
+// of a database or mail server on this machine.
+type HostnameChange struct {
+	OldHost     string
+	NewHost     string
+	URL         string
+	Target      map[string]interface{}
+	Changes     []ValueChange
+	Suggestions []ValueChange
+
+	// previous is the content of values.yaml before Apply, nil when the
+	// file did not exist.
+	previous []byte
+	applied  bool
+}
+
+// PlanHostnameChange computes the values for newHost. Encrypted values are
+// left untouched.
+func PlanHostnameChange(newHost string) (*HostnameChange, error) {
+	layered, err := LoadLayeredValues(nil)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := ChartDefaultValues()
+	if err != nil {
+		pterm.Debug.Printfln("Chart defaults unavailable: %v", err)
+		defaults = map[string]interface{}{}
+	}
+	current := MergeValues(defaults, layered)
+
+	plan := &HostnameChange{NewHost: newHost, URL: "https://" + newHost}
+	if value, ok, _ := GetValue(current, HostnameValuePath); ok {
+		if parsed, err := url.Parse(FormatValue(value)); err == nil {
+			plan.OldHost = parsed.Host
+		}
+	}
+
+	target := copyValues(current).(map[string]interface{})
+	suggested := copyValues(current).(map[string]interface{})
+	if plan.OldHost != "" && plan.OldHost != newHost {
+		oldName := hostOnly(plan.OldHost)
+		for path, value := range FlattenValues(current) {
+			str, ok := value.(string)
+			if !ok || IsEncrypted(str) {
+				continue
+			}
+			// Values with the port keep it; bare names get the new name.
+			replaced := replaceHost(str, plan.OldHost, newHost)
+			if oldName != plan.OldHost {
+				replaced = replaceHost(replaced, oldName, hostOnly(newHost))
+			}
+			if replaced == str {
+				continue
+			}
+			values := suggested
+			if isHostDerivedPath(path) {
+				values = target
+			}
+			if err := SetValue(values, path, replaced); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := SetValue(target, HostnameValuePath, plan.URL); err != nil {
+		return nil, err
+	}
+
+	plan.Target = target
+	plan.Changes = DiffValues(current, target)
+	plan.Suggestions = DiffValues(current, suggested)
+	return plan, nil
+}
+
+// hostDerivedKeys are the keys holding names of NETSOCS itself: ingress and
+// certificate hosts.
+var hostDerivedKeys = map[string]bool{"host": true, "hosts": true, "dnsnames": true, "commonname": true, "domains": true}
+
+// isHostDerivedPath reports whether the value at path follows httpHostname:
+// httpHostname itself, CORS settings, and the host names of ingress and TLS
+// sections.
+func isHostDerivedPath(path string) bool {
+	if path == HostnameValuePath {
+		return true
+	}
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return false
+	}
+	var section, last string
+	for _, segment := range segments {
+		if segment.isIndex {
+			continue
+		}
+		key := strings.ToLower(segment.key)
+		if strings.Contains(key, "cors") {
+			return true
+		}
+		if strings.Contains(key, "ingress") || key == "tls" || strings.Contains(key, "certificate") {
+			section = key
+		}
+		last = key
+	}
+	return section != "" && hostDerivedKeys[last]
+}
+
+// Apply writes the planned changes to values.yaml.
+func (plan *HostnameChange) Apply() error {
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+	valuesPath, err := ValuesFilePath()
+	if err != nil {
+		return err
+	}
+	previous, err := os.ReadFile(valuesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %w", valuesPath, err)
+	}
+	if err := AdoptValues(plan.Target, plan.Changes); err != nil {
+		return err
+	}
+	plan.previous, plan.applied = previous, true
+	return nil
+}
+
+// Revert puts back the values.yaml that Apply replaced, for when a later
+// step of the change fails. The revert is recorded in the history too.
+func (plan *HostnameChange) Revert() error {
+	if !plan.applied {
+		return nil
+	}
+	if plan.previous == nil {
+		valuesPath, err := ValuesFilePath()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(valuesPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := WriteValuesFile(plan.previous); err != nil {
+		return err
+	}
+	plan.applied = false
+	return nil
+}
+
+// ConfiguredHost returns the host name or IP of httpHostname, without port,
+// or an empty string when it is not set.
+func ConfiguredHost() string {
+	return hostOnly(ConfiguredAddress())
+}
+
+// ConfiguredAddress returns the host[:port] of httpHostname, keeping a
+// non-default port, or an empty string when it is not set.
+func ConfiguredAddress() string {
+	values, err := LoadLayeredValues(nil)
+	if err != nil {
+		return ""
+	}
+	value, ok, _ := GetValue(values, HostnameValuePath)
+	if !ok {
+		return ""
+	}
+	parsed, err := url.Parse(FormatValue(value))
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// DaysUntil returns the whole days left until t, negative once t is past.
+func DaysUntil(t time.Time) int {
+	return int(math.Floor(time.Until(t).Hours() / 24))
+}
+
+// hostOnly strips the port and IPv6 brackets of host[:port].
+func hostOnly(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// replaceHost replaces whole occurrences of old in s, so "a.example.com" is
+// not touched when renaming "example.com".
+func replaceHost(s, old, new string) string {
+	isNameChar := func(c byte) bool {
+		return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	var out []byte
+	for i := 0; i < len(s); {
+		if len(s)-i >= len(old) && s[i:i+len(old)] == old {
+			before := i == 0 || !(isNameChar(s[i-1]) || s[i-1] == '.')
+			end := i + len(old)
+			after := end == len(s) || !(isNameChar(s[end]) || s[end] == '.' && end+1 < len(s) && isNameChar(s[end+1]))
+			if before && after {
+				out = append(out, new...)
+				i = end
+				continue
+			}
+		}
+		out = append(out, s[i])
+		i++
+	}
+	return string(out)
+}
+
+// LocalAddresses returns the unicast addresses of the network interfaces of
+// this host.
+func LocalAddresses() ([]netip.Addr, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var result []netip.Addr
+	for _, addr := range addrs {
+		prefix, err := netip.ParsePrefix(addr.String())
+		if err != nil {
+			continue
+		}
+		result = append(result, prefix.Addr().Unmap())
+	}
+	return result, nil
+}
+
+// CheckHostPointsHere returns a warning when host does not resolve, or does
+// not resolve to an address of this server. NAT and load balancers make the
+// latter legitimate, so it is never an error.
+func CheckHostPointsHere(host string) string {
+	host = hostOnly(host)
+	local, err := LocalAddresses()
+	if err != nil {
+		return ""
+	}
+	isLocal := func(addr netip.Addr) bool {
+		for _, l := range local {
+			if l == addr.Unmap() {
+				return true
+			}
+		}
+		return false
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isLocal(addr) {
+			return fmt.Sprintf("%s is not an address of this server", host)
+		}
+		return ""
+	}
+
+	resolved, err := net.LookupHost(host)
+	if err != nil {
+		return fmt.Sprintf("%s does not resolve: %v", host, err)
+	}
+	for _, r := range resolved {
+		if addr, err := netip.ParseAddr(r); err == nil && isLocal(addr) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s resolves to %v, which is not an address of this server", host, resolved)
+}
+
+// CertificateNeeded reports whether the TLS secret must be (re)issued by
+// the local CA to cover host. A certificate issued by the local CA, or a
+// missing one, is reissued; an imported certificate that does not cover
+// host is reported since replacing it would lose it. Nothing is changed, so
+// it can run before values.yaml is written.
+func CertificateNeeded(host string, force bool) (bool, error) {
+	host = hostOnly(host)
+	if ACMEEnabled() && !force {
+		// The ACME resolver requests a certificate for the new host itself.
+		return false, nil
+	}
+	secret, err := GetTLSSecret()
+	switch {
+	case errors.Is(err, ErrTLSSecretNotFound):
+	case err != nil:
+		return false, err
+	case CertificateCovers(secret.Chain[0], host) && !force:
+		return false, nil
+	case !secret.Managed() && !force:
+		return false, fmt.Errorf("the certificate in secret %s does not cover %s and was not generated by the CLI; import a new one or pass --regenerate-cert", secret.Name, host)
+	}
+	return true, nil
+}
+
+// IssueCertificateFor stores a certificate of the local CA for host[:port]
+// in the TLS secret, see IssueLocalCertificate.
+func IssueCertificateFor(host string) (*LocalCA, bool, error) {
+	return IssueLocalCertificate([]string{hostOnly(host)})
+}
+
+// ProbeURL requests rawURL until it answers with a status below 500 or the
+// timeout expires. Certificate errors are ignored when insecure is set, for
+// certificates of the local CA that this machine may not trust.
+func ProbeURL(rawURL string, timeout time.Duration, insecure bool) error {
+	client := NewHTTPClient(10 * time.Second)
+	if insecure {
+		transport := client.Transport.(*http.Transport)
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for {
+		resp, err := client.Get(rawURL)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		lastErr = err
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not answer within %s: %w", rawURL, timeout, lastErr)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
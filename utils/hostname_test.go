@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestHostnameChangeRevert(t *testing.T) {
+	s := useTestSettings(t)
+	t.Setenv("PATH", "")
+	original := "# public address\nhttpHostname: https://old.example.com\ncors:\n  origin: https://old.example.com\n"
+	if err := os.MkdirAll(filepath.Dir(s.ValuesFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.ValuesFile, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanHostnameChange("new.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 2 {
+		t.Fatalf("changes = %v", plan.Changes)
+	}
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	values, err := LoadValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin, _, _ := GetValue(values, "cors.origin"); origin != "https://new.example.com" {
+		t.Errorf("cors.origin = %v after Apply", origin)
+	}
+
+	if err := plan.Revert(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(s.ValuesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != original {
+		t.Errorf("values.yaml after Revert:\n%s", content)
+	}
+	// A second revert is a no-op.
+	if err := plan.Revert(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanHostnameChange(t *testing.T) {
+	s := useTestSettings(t)
+	t.Setenv("PATH", "")
+	values := `httpHostname: https://10.0.0.5
+cors:
+  allowedOrigins:
+    - https://10.0.0.5
+    - https://other.example.com
+ingress:
+  hosts:
+    - host: 10.0.0.5
+  tls:
+    - hosts: [10.0.0.5]
+database:
+  host: 10.0.0.5
+smtp:
+  server: 10.0.0.5:25
+ntp: 10.0.0.50
+`
+	if err := os.MkdirAll(filepath.Dir(s.ValuesFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.ValuesFile, []byte(values), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanHostnameChange("netsocs.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := func(changes []ValueChange) []string {
+		var result []string
+		for _, change := range changes {
+			result = append(result, change.Path)
+		}
+		sort.Strings(result)
+		return result
+	}
+	wantChanges := []string{"cors.allowedOrigins[0]", "httpHostname", "ingress.hosts[0].host", "ingress.tls[0].hosts[0]"}
+	if got := paths(plan.Changes); !reflect.DeepEqual(got, wantChanges) {
+		t.Errorf("changes = %v, want %v", got, wantChanges)
+	}
+	wantSuggestions := []string{"database.host", "smtp.server"}
+	if got := paths(plan.Suggestions); !reflect.DeepEqual(got, wantSuggestions) {
+		t.Errorf("suggestions = %v, want %v", got, wantSuggestions)
+	}
+	if server, _, _ := GetValue(plan.Target, "smtp.server"); server != "10.0.0.5:25" {
+		t.Errorf("smtp.server rewritten to %v", server)
+	}
+}
+
+func TestIsHostDerivedPath(t *testing.T) {
+	tests := map[string]bool{
+		"httpHostname":                    true,
+		"cors.origin":                     true,
+		"api.corsAllowedOrigins[1]":       true,
+		"ingress.hosts[0].host":           true,
+		"ingress.tls[0].hosts[0]":         true,
+		"traefik.ingressRoute.host":       true,
+		"tls.acme.domains[0]":             true,
+		"certificate.dnsNames[0]":         true,
+		"ingress.className":               false,
+		"database.host":                   false,
+		"smtp.host":                       false,
+		"registry.hosts[0]":               false,
+		"ntp.servers[0]":                  false,
+		"ingress.backend.service.name":    false,
+		"tls.acme.email":                  false,
+		`labels.app\.kubernetes\.io/host`: false,
+	}
+	for path, want := range tests {
+		if got := isHostDerivedPath(path); got != want {
+			t.Errorf("isHostDerivedPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestConfiguredAddress(t *testing.T) {
+	tests := []struct {
+		hostname, address, host string
+	}{
+		{"https://netsocs.example.com", "netsocs.example.com", "netsocs.example.com"},
+		{"https://netsocs.example.com:8443", "netsocs.example.com:8443", "netsocs.example.com"},
+		{"https://[2001:db8::1]:8443", "[2001:db8::1]:8443", "2001:db8::1"},
+		{"https://10.0.0.5", "10.0.0.5", "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		s := useTestSettings(t)
+		if err := os.MkdirAll(filepath.Dir(s.ValuesFile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(s.ValuesFile, []byte("httpHostname: "+tt.hostname+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := ConfiguredAddress(); got != tt.address {
+			t.Errorf("ConfiguredAddress() for %s = %q, want %q", tt.hostname, got, tt.address)
+		}
+		if got := ConfiguredHost(); got != tt.host {
+			t.Errorf("ConfiguredHost() for %s = %q, want %q", tt.hostname, got, tt.host)
+		}
+	}
+}
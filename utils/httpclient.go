@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+)
+
+const userAgent = "netsocs-cli"
+
+// NewHTTPClient returns the HTTP client used for every outbound request made
+// by the CLI. Proxy settings, including credentials embedded in the proxy URL,
+// are taken from the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY variables.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	LayerChartDefaults = "chart defaults"
+	LayerSite          = "site"
+	LayerServer        = "server"
+	LayerSecrets       = "secrets"
+)
+
+// ValuesLayer is one file of the values stack. Layers are passed to Helm in
+// order, so later layers override earlier ones:
+//
+//	chart defaults < site.yaml < values.yaml < secrets.yaml
+//
+// site.yaml holds settings shared by every server of a site, values.yaml the
+// per-server overrides edited by the config commands and secrets.yaml the
+// credentials. site.yaml and secrets.yaml live in the NETSOCS home directory,
+// values.yaml wherever the settings point. Only values.yaml is required.
+type ValuesLayer struct {
+	Name string
+	Path string
+}
+
+// ValuesLayers returns the layers whose files exist, in merge order.
+func ValuesLayers() ([]ValuesLayer, error) {
+	valuesPath, err := ValuesFilePath()
+	if err != nil {
+		return nil, err
+	}
+	dir := CurrentSettings().Home
+	candidates := []ValuesLayer{
+		{Name: LayerSite, Path: filepath.Join(dir, "site.yaml")},
+		{Name: LayerServer, Path: valuesPath},
+		{Name: LayerSecrets, Path: filepath.Join(dir, "secrets.yaml")},
+	}
+
+	var layers []ValuesLayer
+	for _, layer := range candidates {
+		if _, err := os.Stat(layer.Path); err == nil {
+			layers = append(layers, layer)
+		}
+	}
+	return layers, nil
+}
+
+// helmValuesArgs returns the --values flags for every layer in order. When
+// the layers hold encrypted secrets, a last "--values -" is added and the
+// returned reader provides the decrypted secrets for the command's stdin.
+func helmValuesArgs() ([]string, io.Reader, error) {
+	layers, err := ValuesLayers()
+	if err != nil {
+		return nil, nil, err
+	}
+	var args []string
+	for _, layer := range layers {
+		args = append(args, "--values", layer.Path)
+	}
+
+	overlay, err := secretOverlay()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decrypting secrets: %w", err)
+	}
+	if overlay == nil {
+		return args, nil, nil
+	}
+	return append(args, "--values", "-"), bytes.NewReader(overlay), nil
+}
+
+func loadLayer(layer ValuesLayer) (map[string]interface{}, error) {
+	content, err := os.ReadFile(layer.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", layer.Path, err)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", layer.Path, err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return values, nil
+}
+
+// LoadLayeredValues merges every layer except the chart defaults. This is
+// what Helm receives as user-supplied values. When server is not nil it is
+// used instead of the content of values.yaml, which lets callers validate a
+// change before writing it.
+func LoadLayeredValues(server map[string]interface{}) (map[string]interface{}, error) {
+	layers, err := ValuesLayers()
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]interface{}{}
+	for _, layer := range layers {
+		var values map[string]interface{}
+		if layer.Name == LayerServer && server != nil {
+			values = server
+		} else if values, err = loadLayer(layer); err != nil {
+			return nil, err
+		}
+		merged = MergeValues(merged, values)
+	}
+	return merged, nil
+}
+
+// EffectiveValues merges the chart defaults with every layer and returns,
+// for each flattened key, the name of the layer that set it.
+func EffectiveValues(defaults map[string]interface{}) (map[string]interface{}, map[string]string, error) {
+	layers, err := ValuesLayers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := defaults
+	origin := map[string]string{}
+	for key := range FlattenValues(defaults) {
+		origin[key] = LayerChartDefaults
+	}
+	for _, layer := range layers {
+		values, err := loadLayer(layer)
+		if err != nil {
+			return nil, nil, err
+		}
+		merged = MergeValues(merged, values)
+		for key := range FlattenValues(values) {
+			origin[key] = layer.Name
+		}
+	}
+	return merged, origin, nil
+}
+
+// AnnotateValues renders values as YAML with a line comment naming the
+// layer of every leaf.
+func AnnotateValues(values map[string]interface{}, origin map[string]string) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(values); err != nil {
+		return nil, err
+	}
+	annotateNode(&doc, "", origin)
+	return encodeNode(&doc, 2)
+}
+
+func annotateNode(node *yaml.Node, path string, origin map[string]string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			annotateNode(node.Content[i+1], joinPath(path, node.Content[i].Value), origin)
+		}
+	case yaml.SequenceNode:
+		if source, ok := origin[path]; ok && len(node.Content) == 0 {
+			node.LineComment = source
+		}
+		for i, item := range node.Content {
+			annotateNode(item, fmt.Sprintf("%s[%d]", path, i), origin)
+		}
+	default:
+		if source, ok := origin[path]; ok {
+			node.LineComment = source
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	caValidity     = 10 * 365 * 24 * time.Hour
+	serverValidity = 397 * 24 * time.Hour
+)
+
+// LocalCA is the certificate authority the CLI creates for installs on an IP
+// address or a private domain, where no public CA can issue a certificate.
+// Clients trust it by importing ca.crt once; server certificates can then be
+// reissued at will.
+type LocalCA struct {
+	Cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	// CertPath is the PEM file to distribute to clients.
+	CertPath string
+}
+
+func localCADir() string {
+	return filepath.Join(CurrentSettings().Home, "ca")
+}
+
+// LoadLocalCA returns the local CA, or nil when it was not created yet.
+func LoadLocalCA() (*LocalCA, error) {
+	dir := localCADir()
+	certPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "ca.key"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the CA key: %w", err)
+	}
+
+	certs, err := ParsePEMCertificates(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("ca.crt: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("ca.key is not a PEM file")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ca.key: %w", err)
+	}
+	return &LocalCA{Cert: certs[0], key: key, CertPath: filepath.Join(dir, "ca.crt")}, nil
+}
+
+// LoadOrCreateLocalCA returns a local CA allowed to issue certificates for
+// hosts, creating it on first use. The CA is name constrained to the hosts
+// it was created for, so importing it on a client does not let it vouch for
+// any other site; when hosts fall outside those constraints, e.g. after a
+// host name change, a new CA is created and the old one kept as ca.crt.old.
+func LoadOrCreateLocalCA(hosts []string) (*LocalCA, bool, error) {
+	ca, err := LoadLocalCA()
+	if err != nil {
+		return nil, false, err
+	}
+	if ca != nil {
+		if ca.Permits(hosts) {
+			return ca, false, nil
+		}
+		if err := retireLocalCA(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("error generating the CA key: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "NETSOCS Local CA", Organization: []string{"NETSOCS"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	setNameConstraints(template, hosts)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating the CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, false, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	dir := localCADir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, false, err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "ca.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, false, err
+	}
+	certPath := filepath.Join(dir, "ca.crt")
+	if err := writeFileAtomic(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, false, err
+	}
+	return &LocalCA{Cert: cert, key: key, CertPath: certPath}, true, nil
+}
+
+// setNameConstraints limits a CA to hosts. Host names also permit their
+// subdomains. A CA for names only excludes every IP address and one for IPs
+// only permits the reserved "invalid" domain, since an empty list of
+// constraints would allow anything of that type.
+func setNameConstraints(template *x509.Certificate, hosts []string) {
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			template.PermittedIPRanges = append(template.PermittedIPRanges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else {
+			template.PermittedDNSDomains = append(template.PermittedDNSDomains, host)
+		}
+	}
+	if len(template.PermittedIPRanges) == 0 {
+		template.ExcludedIPRanges = []*net.IPNet{
+			{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 8*net.IPv4len)},
+			{IP: net.IPv6zero, Mask: net.CIDRMask(0, 8*net.IPv6len)},
+		}
+	}
+	if len(template.PermittedDNSDomains) == 0 {
+		template.PermittedDNSDomains = []string{"invalid"}
+	}
+	template.PermittedDNSDomainsCritical = true
+}
+
+// Permits reports whether the name constraints of the CA allow every host.
+// A CA created before name constraints were added permits anything.
+func (ca *LocalCA) Permits(hosts []string) bool {
+	cert := ca.Cert
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if len(cert.PermittedIPRanges) > 0 && !ipInRanges(ip, cert.PermittedIPRanges) || ipInRanges(ip, cert.ExcludedIPRanges) {
+				return false
+			}
+			continue
+		}
+		if len(cert.PermittedDNSDomains) == 0 {
+			continue
+		}
+		permitted := false
+		for _, domain := range cert.PermittedDNSDomains {
+			if strings.EqualFold(host, domain) || strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(domain)) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return false
+		}
+	}
+	return true
+}
+
+func ipInRanges(ip net.IP, ranges []*net.IPNet) bool {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// retireLocalCA renames the CA files to *.old, replacing older ones.
+func retireLocalCA() error {
+	dir := localCADir()
+	for _, name := range []string{"ca.crt", "ca.key"} {
+		path := filepath.Join(dir, name)
+		if err := os.Rename(path, path+".old"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error retiring the local CA: %w", err)
+		}
+	}
+	return nil
+}
+
+// Issue returns a server certificate for hosts, followed by the CA
+// certificate, and its private key, all PEM encoded.
+func (ca *LocalCA) Issue(hosts []string) (chainPEM, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("no host names for the certificate")
+	}
+	if !ca.Permits(hosts) {
+		return nil, nil, fmt.Errorf("the local CA is not allowed to issue certificates for %s", strings.Join(hosts, ", "))
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating key: %w", err)
+	}
+
+	notAfter := time.Now().Add(serverValidity)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"NETSOCS"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	chainPEM = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})...)
+	return chainPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// IssueLocalCertificate issues a certificate for hosts from the local CA,
+// creating the CA if needed, and stores it in the TLS secret. It returns the
+// CA and whether it was created, in which case clients must import it.
+func IssueLocalCertificate(hosts []string) (*LocalCA, bool, error) {
+	ca, created, err := LoadOrCreateLocalCA(hosts)
+	if err != nil {
+		return nil, false, err
+	}
+	chainPEM, keyPEM, err := ca.Issue(hosts)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := ApplyTLSSecret(TLSSecretName(), chainPEM, keyPEM, CertSourceLocalCA); err != nil {
+		return nil, false, err
+	}
+	return ca, created, nil
+}
+
+// CertificateHosts returns the DNS names and IP addresses of cert.
+func CertificateHosts(cert *x509.Certificate) []string {
+	hosts := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	return hosts
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	return serial
+}
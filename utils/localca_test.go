@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalCANameConstraints(t *testing.T) {
+	tests := []struct {
+		name      string
+		caHosts   []string
+		permitted []string
+		rejected  []string
+	}{
+		{
+			name:      "host name",
+			caHosts:   []string{"netsocs.example.com"},
+			permitted: []string{"netsocs.example.com", "api.netsocs.example.com"},
+			rejected:  []string{"example.com", "bank.example", "10.0.0.5"},
+		},
+		{
+			name:      "IPv4",
+			caHosts:   []string{"10.0.0.5"},
+			permitted: []string{"10.0.0.5"},
+			rejected:  []string{"10.0.0.6", "netsocs.example.com", "2001:db8::1"},
+		},
+		{
+			name:      "IPv6 and name",
+			caHosts:   []string{"2001:db8::1", "netsocs.lan"},
+			permitted: []string{"2001:db8::1", "netsocs.lan"},
+			rejected:  []string{"2001:db8::2", "10.0.0.5", "other.lan"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestSettings(t)
+			ca, created, err := LoadOrCreateLocalCA(tt.caHosts)
+			if err != nil || !created {
+				t.Fatalf("LoadOrCreateLocalCA = %v, %v", created, err)
+			}
+			if !ca.Cert.PermittedDNSDomainsCritical {
+				t.Error("name constraints are not critical")
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.Cert)
+
+			for _, host := range tt.permitted {
+				chainPEM, _, err := ca.Issue([]string{host})
+				if err != nil {
+					t.Fatalf("Issue(%s): %v", host, err)
+				}
+				chain, err := ParsePEMCertificates(chainPEM)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := chain[0].Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+					t.Errorf("certificate for %s does not verify: %v", host, err)
+				}
+			}
+
+			for _, host := range tt.rejected {
+				if ca.Permits([]string{host}) {
+					t.Errorf("CA permits %s", host)
+				}
+				if _, _, err := ca.Issue([]string{host}); err == nil {
+					t.Errorf("Issue(%s) succeeded", host)
+				}
+				// Clients reject a certificate signed outside the constraints.
+				leaf := signUnchecked(t, ca, host)
+				if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err == nil {
+					t.Errorf("certificate for %s verifies despite the name constraints", host)
+				}
+			}
+		})
+	}
+}
+
+// signUnchecked signs a server certificate for host, bypassing Issue.
+func signUnchecked(t *testing.T, ca *LocalCA, host string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestLoadOrCreateLocalCARecreates(t *testing.T) {
+	useTestSettings(t)
+	first, created, err := LoadOrCreateLocalCA([]string{"old.example.com"})
+	if err != nil || !created {
+		t.Fatalf("LoadOrCreateLocalCA = %v, %v", created, err)
+	}
+	same, created, err := LoadOrCreateLocalCA([]string{"www.old.example.com"})
+	if err != nil || created || !same.Cert.Equal(first.Cert) {
+		t.Fatalf("CA was not reused: created=%v err=%v", created, err)
+	}
+
+	second, created, err := LoadOrCreateLocalCA([]string{"new.example.com"})
+	if err != nil || !created || second.Cert.Equal(first.Cert) {
+		t.Fatalf("CA was not recreated: created=%v err=%v", created, err)
+	}
+	if _, err := os.Stat(filepath.Join(localCADir(), "ca.crt.old")); err != nil {
+		t.Errorf("old CA not kept: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layers diagnosed for each connectivity target, in the order they happen.
+const (
+	LayerDNS  = "DNS"
+	LayerTCP  = "TCP"
+	LayerTLS  = "TLS"
+	LayerHTTP = "HTTP"
+	LayerNTP  = "NTP"
+)
+
+// LayerResult is the outcome of one layer of a connectivity check.
+type LayerResult struct {
+	Layer    string
+	OK       bool
+	Detail   string
+	Duration time.Duration
+	Err      error
+}
+
+// FailedLayer returns the first layer that failed, or nil.
+func (r ConnectivityResult) FailedLayer() *LayerResult {
+	for i := range r.Layers {
+		if !r.Layers[i].OK {
+			return &r.Layers[i]
+		}
+	}
+	return nil
+}
+
+// DescribeResolver returns the name servers of /etc/resolv.conf, which the
+// Go resolver and most system resolvers use.
+func DescribeResolver() string {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return "system resolver"
+	}
+	var servers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return "system resolver (no nameserver in /etc/resolv.conf)"
+	}
+	return fmt.Sprintf("%s (from /etc/resolv.conf)", strings.Join(servers, ", "))
+}
+
+func checkTarget(ctx context.Context, client *http.Client, target ConnectivityTarget, timeout time.Duration) ConnectivityResult {
+	result := ConnectivityResult{Target: target}
+	start := time.Now()
+
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Host == "" {
+		result.Status = "Invalid"
+		result.Err = fmt.Errorf("invalid URL %q", target.URL)
+		return result
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		result.Layers, result.Status = diagnoseHTTP(ctx, client, target)
+	case "tcp":
+		result.Layers = diagnoseTCP(ctx, u.Host, timeout)
+		result.Status = "Open"
+	case "ntp":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "123")
+		}
+		result.Layers = diagnoseNTP(ctx, host, timeout)
+		result.Status = "Answered"
+	default:
+		result.Status = "Invalid"
+		result.Err = fmt.Errorf("unsupported scheme %q, use http, https, tcp or ntp", u.Scheme)
+		return result
+	}
+
+	result.OK = true
+	if failed := result.FailedLayer(); failed != nil {
+		result.OK = false
+		result.Err = failed.Err
+		if failed.Layer != LayerHTTP {
+			result.Status = failed.Layer + " error"
+		}
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// diagnoseHTTP follows the request with httptrace, so the layers are those
+// actually used, including the connection to a proxy when one is set. Each
+// redirect is recorded as an HTTP layer followed by the layers of the next
+// hop.
+func diagnoseHTTP(ctx context.Context, client *http.Client, target ConnectivityTarget) ([]LayerResult, string) {
+	var mu sync.Mutex
+	var layers []LayerResult
+	var dnsStart, connectStart, tlsStart time.Time
+	var dnsHost string
+	var tcp *LayerResult
+	hops := 0
+	record := func(layer LayerResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		layers = append(layers, layer)
+	}
+	// flushTCP records the pending TCP layer; the caller holds mu.
+	flushTCP := func() {
+		if tcp != nil {
+			layers = append(layers, *tcp)
+			tcp = nil
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			mu.Lock()
+			defer mu.Unlock()
+			flushTCP()
+			if hops > 0 {
+				layers = append(layers, LayerResult{Layer: LayerHTTP, OK: true, Detail: "redirected to " + hostPort})
+			}
+			hops++
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			flushTCP()
+			dnsStart, dnsHost = time.Now(), info.Host
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			layer := LayerResult{Layer: LayerDNS, OK: info.Err == nil, Duration: time.Since(dnsStart), Err: info.Err}
+			if info.Err == nil {
+				var addrs []string
+				for _, addr := range info.Addrs {
+					addrs = append(addrs, addr.String())
+				}
+				layer.Detail = fmt.Sprintf("%s: %s", dnsHost, strings.Join(addrs, ", "))
+			}
+			record(layer)
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connectStart = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			// With several addresses, the connection succeeds if any does.
+			layer := LayerResult{Layer: LayerTCP, OK: err == nil, Detail: addr, Duration: time.Since(connectStart), Err: err}
+			if tcp == nil || !tcp.OK {
+				tcp = &layer
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			flushTCP()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			layer := LayerResult{Layer: LayerTLS, OK: err == nil, Duration: time.Since(tlsStart)}
+			if err != nil {
+				layer.Err = explainTLSError(err)
+			} else if len(state.PeerCertificates) > 0 {
+				layer.Detail = fmt.Sprintf("%s, issued by %s", tls.VersionName(state.Version), describeIssuer(state.PeerCertificates[0]))
+			}
+			record(layer)
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target.URL, nil)
+	if err != nil {
+		return []LayerResult{{Layer: LayerHTTP, Err: err}}, "Invalid"
+	}
+	req.Header.Set("User-Agent", userAgent)
+	requestStart := time.Now()
+	resp, err := client.Do(req)
+
+	mu.Lock()
+	defer mu.Unlock()
+	flushTCP()
+	if err != nil {
+		// Errors of a layer already recorded are not repeated; otherwise the
+		// request failed at the HTTP layer, e.g. a proxy refusing it or a
+		// timeout waiting for the response.
+		for _, layer := range layers {
+			if !layer.OK {
+				return layers, "Error"
+			}
+		}
+		return append(layers, LayerResult{Layer: LayerHTTP, Duration: time.Since(requestStart), Err: err}), "Error"
+	}
+	resp.Body.Close()
+
+	status := fmt.Sprintf("HTTP %d", resp.StatusCode)
+	layer := LayerResult{Layer: LayerHTTP, OK: true, Detail: status, Duration: time.Since(requestStart)}
+	if len(target.ExpectedStatus) == 0 {
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			layer.OK = false
+			layer.Err = fmt.Errorf("%s, expected 2xx or 3xx", status)
+		}
+	} else {
+		layer.OK = false
+		for _, code := range target.ExpectedStatus {
+			if resp.StatusCode == code {
+				layer.OK = true
+			}
+		}
+		if !layer.OK {
+			layer.Err = fmt.Errorf("%s, expected %s", status, joinInts(target.ExpectedStatus))
+		}
+	}
+	return append(layers, layer), status
+}
+
+// explainTLSError points at TLS interception when the server certificate is
+// signed by an unknown authority, which is what an inspecting proxy does.
+func explainTLSError(err error) error {
+	var unknown x509.UnknownAuthorityError
+	if errors.As(err, &unknown) && unknown.Cert != nil {
+		return fmt.Errorf("certificate issued by %s is not trusted; a TLS-intercepting proxy is likely, ask IT to exempt the host or install its root CA", describeIssuer(unknown.Cert))
+	}
+	var hostname x509.HostnameError
+	if errors.As(err, &hostname) && hostname.Certificate != nil {
+		return fmt.Errorf("certificate issued by %s is for %s; the connection is being redirected", describeIssuer(hostname.Certificate), strings.Join(CertificateHosts(hostname.Certificate), ", "))
+	}
+	return err
+}
+
+func describeIssuer(cert *x509.Certificate) string {
+	issuer := cert.Issuer.CommonName
+	if len(cert.Issuer.Organization) > 0 && cert.Issuer.Organization[0] != issuer {
+		issuer = fmt.Sprintf("%s (%s)", issuer, cert.Issuer.Organization[0])
+	}
+	if issuer == "" {
+		issuer = cert.Issuer.String()
+	}
+	return issuer
+}
+
+// resolve looks up the host of hostport, recording the DNS layer unless it
+// is an IP address.
+func resolve(ctx context.Context, hostport string) ([]LayerResult, []string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return []LayerResult{{Layer: LayerDNS, Err: err}}, nil
+	}
+	if net.ParseIP(host) != nil {
+		return nil, []string{hostport}
+	}
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	layer := LayerResult{Layer: LayerDNS, OK: err == nil, Duration: time.Since(start), Err: err}
+	if err != nil {
+		return []LayerResult{layer}, nil
+	}
+	layer.Detail = fmt.Sprintf("%s: %s", host, strings.Join(addrs, ", "))
+	var hostports []string
+	for _, addr := range addrs {
+		hostports = append(hostports, net.JoinHostPort(addr, port))
+	}
+	return []LayerResult{layer}, hostports
+}
+
+func diagnoseTCP(ctx context.Context, hostport string, timeout time.Duration) []LayerResult {
+	layers, addrs := resolve(ctx, hostport)
+	if addrs == nil {
+		return layers
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	var layer LayerResult
+	for _, addr := range addrs {
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		layer = LayerResult{Layer: LayerTCP, OK: err == nil, Detail: addr, Duration: time.Since(start), Err: err}
+		if err == nil {
+			conn.Close()
+			break
+		}
+	}
+	return append(layers, layer)
+}
+
+func diagnoseNTP(ctx context.Context, hostport string, timeout time.Duration) []LayerResult {
+	layers, addrs := resolve(ctx, hostport)
+	if addrs == nil {
+		return layers
+	}
+	start := time.Now()
+	err := queryNTP(ctx, addrs[0], timeout)
+	return append(layers, LayerResult{Layer: LayerNTP, OK: err == nil, Detail: addrs[0], Duration: time.Since(start), Err: err})
+}
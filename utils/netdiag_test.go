@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagnoseHTTPRedirect(t *testing.T) {
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer final.Close()
+	// Redirect to the host name, so the second hop resolves it.
+	finalURL := strings.Replace(final.URL, "127.0.0.1", "localhost", 1)
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, finalURL+"/health", http.StatusFound)
+	}))
+	defer first.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	layers, status := diagnoseHTTP(context.Background(), client, ConnectivityTarget{URL: first.URL})
+	if status != "HTTP 200" {
+		t.Fatalf("status = %q", status)
+	}
+
+	var got []string
+	for _, layer := range layers {
+		if !layer.OK && layer.Layer != LayerTCP {
+			t.Errorf("%s layer failed: %v", layer.Layer, layer.Err)
+		}
+		got = append(got, layer.Layer)
+	}
+	want := []string{LayerTCP, LayerHTTP, LayerDNS, LayerTCP, LayerHTTP}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("layers = %v, want %v", got, want)
+	}
+	firstAddr := strings.TrimPrefix(first.URL, "http://")
+	if layers[0].Detail != firstAddr || !layers[0].OK {
+		t.Errorf("first hop TCP = %+v, want %s", layers[0], firstAddr)
+	}
+	if !strings.Contains(layers[1].Detail, "redirected to localhost:") {
+		t.Errorf("redirect layer = %q", layers[1].Detail)
+	}
+	if !layers[3].OK || !strings.HasSuffix(layers[3].Detail, ":"+final.URL[strings.LastIndex(final.URL, ":")+1:]) {
+		t.Errorf("second hop TCP = %+v", layers[3])
+	}
+}
@@ -1,104 +1,301 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pterm/pterm"
 )
 
-var urlsList = []string{
-	"https://netsocs.com",
-	"https://netsocs-team.github.io/netsocs-helm-chart",
-	"https://ghcr.io",
-	"https://plugins.traefik.io",
-	"http://github.com/",
-	"https://hub.docker.com/",
+const (
+	defaultConnectivityWorkers  = 8
+	defaultConnectivityTimeout  = 10 * time.Second
+	defaultConnectivityDeadline = time.Minute
+)
+
+// ConnectivityTarget is an endpoint checked by "enviroment". The scheme of
+// URL selects the check: http and https send a GET request, tcp://host:port
+// opens a connection and ntp://host[:port] queries a time server.
+type ConnectivityTarget struct {
+	Name string `yaml:"name,omitempty"`
+	URL  string `yaml:"url"`
+	// ExpectedStatus lists the accepted HTTP codes; any 2xx or 3xx code is
+	// accepted when empty.
+	ExpectedStatus []int `yaml:"expectedStatus,omitempty"`
 }
 
-func CheckNetworkConnection() bool {
-	// Configure pterm style
-	pterm.Info.Println("🔍 Checking enviroment connectivity...")
+// ConnectivitySettings is the "connectivity" section of the CLI config file.
+// Targets are added to the defaults unless ReplaceDefaults is set, so a site
+// can list its private registries or NTP server.
+type ConnectivitySettings struct {
+	Targets         []ConnectivityTarget `yaml:"targets,omitempty"`
+	ReplaceDefaults bool                 `yaml:"replaceDefaults,omitempty"`
+	// Workers is the number of checks run at the same time.
+	Workers int `yaml:"workers,omitempty"`
+	// Timeout applies to each target, Deadline to the whole run.
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+	Deadline time.Duration `yaml:"deadline,omitempty"`
+}
 
-	// Create a table to show results
-	tableData := pterm.TableData{
-		{"URL", "Status", "Response Time", "HTTP Code"},
+// DefaultConnectivityTargets are the endpoints an install needs.
+var DefaultConnectivityTargets = []ConnectivityTarget{
+	{URL: "https://netsocs.com"},
+	{URL: "https://netsocs-team.github.io/netsocs-helm-chart"},
+	{URL: "https://ghcr.io"},
+	{URL: "https://plugins.traefik.io"},
+	{URL: "http://github.com/"},
+	{URL: "https://hub.docker.com/"},
+}
+
+// EffectiveTargets returns the targets to check.
+func (c ConnectivitySettings) EffectiveTargets() []ConnectivityTarget {
+	if c.ReplaceDefaults {
+		return c.Targets
 	}
+	return append(append([]ConnectivityTarget{}, DefaultConnectivityTargets...), c.Targets...)
+}
 
-	// Configure HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+func (c *ConnectivitySettings) complete() {
+	if c.Workers <= 0 {
+		c.Workers = defaultConnectivityWorkers
 	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultConnectivityTimeout
+	}
+	if c.Deadline <= 0 {
+		c.Deadline = defaultConnectivityDeadline
+	}
+}
 
-	// Create progress bar
-	progress, _ := pterm.DefaultProgressbar.WithTotal(len(urlsList)).WithTitle("Checking URLs").Start()
+// Label is the name shown for the target.
+func (t ConnectivityTarget) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.URL
+}
 
-	successCount := 0
-	failedCount := 0
+// ConnectivityResult is the outcome of checking one target.
+type ConnectivityResult struct {
+	Target   ConnectivityTarget
+	OK       bool
+	Status   string
+	Duration time.Duration
+	Err      error
+	// Layers are the steps of the check up to the first failing one.
+	Layers []LayerResult
+}
 
-	for _, url := range urlsList {
-		progress.UpdateTitle(fmt.Sprintf("Checking: %s", url))
+// CheckConnectivity checks targets with a pool of workers and returns the
+// results in the order of targets. Targets not checked when the deadline
+// expires are reported as failed. progress, if not nil, is called from the
+// calling goroutine after each result.
+func CheckConnectivity(targets []ConnectivityTarget, config ConnectivitySettings, progress func(ConnectivityResult)) []ConnectivityResult {
+	config.complete()
+	ctx, cancel := context.WithTimeout(context.Background(), config.Deadline)
+	defer cancel()
 
-		start := time.Now()
-		resp, err := client.Get(url)
-		duration := time.Since(start)
+	type indexed struct {
+		index  int
+		result ConnectivityResult
+	}
+	jobs := make(chan int)
+	done := make(chan indexed, len(targets))
+	client := NewHTTPClient(config.Timeout)
+	// A new connection per target, so every layer is diagnosed.
+	client.Transport.(*http.Transport).DisableKeepAlives = true
 
-		var status, statusCode string
+	for w := 0; w < config.Workers; w++ {
+		go func() {
+			for i := range jobs {
+				done <- indexed{i, checkTarget(ctx, client, targets[i], config.Timeout)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range targets {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-		if err != nil {
-			status = "❌ Error"
-			statusCode = "N/A"
-			failedCount++
-		} else {
-			defer resp.Body.Close()
-			statusCode = fmt.Sprintf("%d", resp.StatusCode)
-
-			if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-				status = "✅ Connected"
-				successCount++
-			} else {
-				status = "⚠️  HTTP Error"
-				failedCount++
+	results := make([]ConnectivityResult, len(targets))
+	checked := make([]bool, len(targets))
+	for received := 0; received < len(targets); received++ {
+		select {
+		case r := <-done:
+			results[r.index] = r.result
+			checked[r.index] = true
+			if progress != nil {
+				progress(r.result)
+			}
+		case <-ctx.Done():
+			for i, ok := range checked {
+				if !ok {
+					results[i] = ConnectivityResult{
+						Target: targets[i],
+						Status: "Timeout",
+						Err:    fmt.Errorf("not checked within the %s deadline", config.Deadline),
+					}
+				}
 			}
+			return results
 		}
+	}
+	return results
+}
 
-		// Agregar fila a la tabla
-		tableData = append(tableData, []string{
-			url,
-			status,
-			fmt.Sprintf("%.2fs", duration.Seconds()),
-			statusCode,
-		})
+// queryNTP sends an SNTP client request to host:port and waits for a server
+// reply.
+func queryNTP(ctx context.Context, host string, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 
-		progress.Increment()
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	request := make([]byte, 48)
+	request[0] = 0x1B // leap indicator 0, version 3, client mode
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	if err != nil {
+		return err
+	}
+	if n < 48 || response[0]&0x07 != 4 {
+		return fmt.Errorf("invalid NTP response")
+	}
+	return nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func CheckNetworkConnection(config ConnectivitySettings, verbose bool) bool {
+	targets := config.EffectiveTargets()
+	if len(targets) == 0 {
+		pterm.Warning.Println("No connectivity targets configured")
+		return true
+	}
+
+	// Configure pterm style
+	pterm.Info.Println("🔍 Checking enviroment connectivity...")
+	pterm.Info.Printfln("DNS resolver: %s", DescribeResolver())
+	if proxy := DescribeProxy(); proxy != "" {
+		pterm.Info.Printfln("HTTP(S) targets are checked through the proxy %s; the DNS and TCP layers are those of the proxy", proxy)
 	}
 
+	// Create progress bar
+	progress, _ := pterm.DefaultProgressbar.WithTotal(len(targets)).WithTitle("Checking targets").Start()
+	results := CheckConnectivity(targets, config, func(result ConnectivityResult) {
+		progress.UpdateTitle(fmt.Sprintf("Checked: %s", result.Target.Label()))
+		progress.Increment()
+	})
 	progress.Stop()
 
+	// Create a table to show results
+	tableData := pterm.TableData{
+		{"Target", "Status", "Response Time", "Failed Layer", "Result"},
+	}
+	successCount := 0
+	failedCount := 0
+	for _, result := range results {
+		var status string
+		switch {
+		case result.OK:
+			status = "✅ Connected"
+			successCount++
+		case strings.HasPrefix(result.Status, "HTTP "):
+			status = "⚠️  HTTP Error"
+			failedCount++
+		default:
+			status = "❌ Error"
+			failedCount++
+		}
+
+		detail := result.Status
+		if result.Err != nil {
+			detail = fmt.Sprintf("%s: %v", result.Status, result.Err)
+		}
+		duration := "N/A"
+		if result.Duration > 0 {
+			duration = fmt.Sprintf("%.2fs", result.Duration.Seconds())
+		}
+		failedLayer := "-"
+		if failed := result.FailedLayer(); failed != nil {
+			failedLayer = failed.Layer
+		}
+		tableData = append(tableData, []string{result.Target.Label(), status, duration, failedLayer, detail})
+	}
+
 	// Show summary
 	pterm.Println()
 	pterm.DefaultSection.Println("📊 Verification Results")
 
 	// Show statistics
 	stats := fmt.Sprintf("✅ Connected: %d | ❌ Failed: %d | 📊 Total: %d",
-		successCount, failedCount, len(urlsList))
+		successCount, failedCount, len(targets))
 	pterm.Info.Println(stats)
 
 	// Show table
 	pterm.Println()
 	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
 
+	// Show the layers of failed targets, or of all of them when verbose
+	for _, result := range results {
+		if (verbose || !result.OK) && len(result.Layers) > 0 {
+			printLayers(result)
+		}
+	}
+
 	// Show recommendations
 	pterm.Println()
 	if failedCount == 0 {
-		pterm.Success.Println("🎉 Excellent! All URLs are accessible.")
+		pterm.Success.Println("🎉 Excellent! All targets are accessible.")
 		return true
-	} else if failedCount < len(urlsList)/2 {
-		pterm.Warning.Println("⚠️  Some URLs are not accessible. Check the enviroment network connection.")
+	} else if failedCount < len(targets)/2 {
+		pterm.Warning.Println("⚠️  Some targets are not accessible. Check the enviroment network connection.")
 		return false
 	} else {
-		pterm.Error.Println("🚨 Many URLs are not accessible. Possible network blocking detected.")
+		pterm.Error.Println("🚨 Many targets are not accessible. Possible network blocking detected.")
 		return false
 	}
 }
+
+func printLayers(result ConnectivityResult) {
+	pterm.Println()
+	pterm.DefaultSection.WithLevel(2).Println(result.Target.Label())
+	for _, layer := range result.Layers {
+		parts := []string{fmt.Sprintf("%-4s %6.0fms", layer.Layer, float64(layer.Duration.Microseconds())/1000)}
+		if layer.Detail != "" {
+			parts = append(parts, layer.Detail)
+		}
+		if layer.OK {
+			pterm.Success.Println(strings.Join(parts, "  "))
+		} else {
+			pterm.Error.Println(strings.Join(append(parts, layer.Err.Error()), "  "))
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// RequiredPort is a host port NETSOCS needs open to clients.
+type RequiredPort struct {
+	Port     int
+	Protocol string
+	Purpose  string
+}
+
+func (p RequiredPort) String() string {
+	return fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+}
+
+// RequiredPorts returns the ports to check: HTTP and HTTPS, the port of
+// httpHostname and the ports published by the Kind nodes. hostPort and
+// nodePort values of the chart are left out: with Kind they only open ports
+// inside the node container, not on this host.
+func RequiredPorts() []RequiredPort {
+	ports := map[string]RequiredPort{}
+	add := func(port int, protocol, purpose string) {
+		if port <= 0 || port > 65535 {
+			return
+		}
+		key := fmt.Sprintf("%d/%s", port, protocol)
+		if _, ok := ports[key]; !ok {
+			ports[key] = RequiredPort{Port: port, Protocol: protocol, Purpose: purpose}
+		}
+	}
+	add(80, "tcp", "HTTP (redirect to HTTPS, ACME HTTP-01)")
+	add(443, "tcp", "HTTPS (web UI and API)")
+
+	if _, portText, err := net.SplitHostPort(ConfiguredAddress()); err == nil {
+		port, _ := strconv.Atoi(portText)
+		add(port, "tcp", "httpHostname")
+	}
+
+	mappings, _ := KindPortMappings()
+	for _, mapping := range mappings {
+		add(mapping.Port, mapping.Protocol, mapping.Purpose)
+	}
+
+	result := make([]RequiredPort, 0, len(ports))
+	for _, port := range ports {
+		result = append(result, port)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Port != result[j].Port {
+			return result[i].Port < result[j].Port
+		}
+		return result[i].Protocol < result[j].Protocol
+	})
+	return result
+}
+
+// KindPublishedPorts returns the ports published by the Kind nodes, keyed
+// by "port/protocol". Docker DNATs them to the node container, so their
+// traffic goes through the FORWARD chain and never reaches the input rules
+// of ufw, firewalld or nftables.
+func KindPublishedPorts() map[string]bool {
+	published := map[string]bool{}
+	mappings, _ := KindPortMappings()
+	for _, mapping := range mappings {
+		published[mapping.String()] = true
+	}
+	return published
+}
+
+// KindPortMappings returns the host ports published by the Kind nodes.
+func KindPortMappings() ([]RequiredPort, error) {
+	nodes, err := KindNodes()
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	output, err := exec.Command("docker", append([]string{"inspect", "--format", "{{json .HostConfig.PortBindings}}"}, nodes...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting Kind nodes: %s", commandError(err))
+	}
+	var ports []RequiredPort
+	for i, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		var bindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		}
+		if err := json.Unmarshal([]byte(line), &bindings); err != nil {
+			continue
+		}
+		for containerPort, hosts := range bindings {
+			_, protocol, _ := strings.Cut(containerPort, "/")
+			for _, host := range hosts {
+				port, err := strconv.Atoi(host.HostPort)
+				// The API server mapping is only reachable from localhost.
+				if err != nil || host.HostIP == "127.0.0.1" {
+					continue
+				}
+				ports = append(ports, RequiredPort{Port: port, Protocol: protocol, Purpose: "Kind port mapping of " + nodes[i]})
+			}
+		}
+	}
+	return ports, nil
+}
+
+// Listener is a socket listening on the host.
+type Listener struct {
+	Port     int
+	Protocol string
+	Address  string
+	// PID and Process are zero and empty when the owner is not visible,
+	// usually because it belongs to another user and the CLI is not root.
+	PID     int
+	Process string
+}
+
+// Listeners returns the listening TCP and bound UDP sockets of the host,
+// from /proc/net.
+func Listeners() ([]Listener, error) {
+	owners := socketOwners()
+	var listeners []Listener
+	for _, table := range []struct{ file, protocol, state string }{
+		{"tcp", "tcp", "0A"}, {"tcp6", "tcp", "0A"},
+		{"udp", "udp", "07"}, {"udp6", "udp", "07"},
+	} {
+		data, err := os.ReadFile(filepath.Join("/proc/net", table.file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[3] != table.state {
+				continue
+			}
+			address, portHex, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			port, err := strconv.ParseInt(portHex, 16, 32)
+			if err != nil {
+				continue
+			}
+			listener := Listener{Port: int(port), Protocol: table.protocol, Address: procNetAddress(address)}
+			if owner, ok := owners[fields[9]]; ok {
+				listener.PID, listener.Process = owner.pid, owner.process
+			}
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners, nil
+}
+
+// procNetAddress decodes the hex address of /proc/net, stored as 32-bit
+// little-endian words.
+func procNetAddress(hex string) string {
+	var bytes []byte
+	for i := 0; i+8 <= len(hex); i += 8 {
+		word, err := strconv.ParseUint(hex[i:i+8], 16, 32)
+		if err != nil {
+			return hex
+		}
+		bytes = append(bytes, byte(word), byte(word>>8), byte(word>>16), byte(word>>24))
+	}
+	switch len(bytes) {
+	case 4:
+		return netip.AddrFrom4([4]byte(bytes)).String()
+	case 16:
+		return "[" + netip.AddrFrom16([16]byte(bytes)).String() + "]"
+	}
+	return hex
+}
+
+type socketOwner struct {
+	pid     int
+	process string
+}
+
+// socketOwners maps socket inodes to the process holding them, by reading
+// the file descriptors under /proc.
+func socketOwners() map[string]socketOwner {
+	owners := map[string]socketOwner{}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		var process string
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			if process == "" {
+				comm, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+				process = strings.TrimSpace(string(comm))
+			}
+			owners[strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")] = socketOwner{pid: pid, process: process}
+		}
+	}
+	return owners
+}
+
+// PortCheck is the state of a required port on this host.
+type PortCheck struct {
+	RequiredPort
+	Level    CheckLevel
+	Owner    string
+	Firewall string
+	Fix      string
+}
+
+// dockerProcesses publish container ports on the host.
+var dockerProcesses = map[string]bool{"docker-proxy": true, "dockerd": true, "rootlesskit": true}
+
+// CheckPorts reports, for each required port, who is listening on it and
+// whether the firewall lets clients in.
+func CheckPorts(ports []RequiredPort, firewall *FirewallStatus) ([]PortCheck, error) {
+	listeners, err := Listeners()
+	if err != nil {
+		return nil, err
+	}
+	kindPorts := KindPublishedPorts()
+
+	var checks []PortCheck
+	for _, port := range ports {
+		check := PortCheck{RequiredPort: port, Owner: "free"}
+		for _, listener := range listeners {
+			if listener.Port != port.Port || listener.Protocol != port.Protocol {
+				continue
+			}
+			switch {
+			case listener.Process == "":
+				check.Owner = "unknown process"
+				check.Level = LevelWarning
+				check.Fix = "Run as root to see which process holds the port"
+			case dockerProcesses[listener.Process] && kindPorts[port.String()]:
+				check.Owner = "Kind (" + listener.Process + ")"
+			default:
+				check.Owner = fmt.Sprintf("%s (pid %d)", listener.Process, listener.PID)
+				check.Level = LevelCritical
+				check.Fix = fmt.Sprintf("Stop %s or move it to another port (e.g. sudo systemctl disable --now <its service>)", listener.Process)
+			}
+			break
+		}
+
+		check.Firewall = "no firewall"
+		switch {
+		case firewall == nil || !firewall.Active:
+		case kindPorts[port.String()]:
+			check.Firewall = firewall.Name + ": bypassed (forwarded by Docker)"
+		default:
+			allowed, known := firewall.Allows(port)
+			switch {
+			case !known && firewall.limits(port):
+				check.Firewall = firewall.Name + ": unknown"
+				if check.Level < LevelWarning {
+					check.Level = LevelWarning
+					check.Fix = "The firewall accepts the port only from some sources or interfaces; check that clients are among them"
+				}
+			case !known:
+				check.Firewall = firewall.Name + ": unknown"
+			case allowed:
+				check.Firewall = firewall.Name + ": allowed"
+			default:
+				check.Firewall = firewall.Name + ": blocked"
+				if check.Level < LevelCritical {
+					check.Level = LevelCritical
+					check.Fix = "Run 'netsocs firewall apply' to open the required ports"
+				}
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// CheckPortsAndFirewall runs and prints the port and firewall checks. It
+// returns false when a port is taken or blocked.
+func CheckPortsAndFirewall(extra []RequiredPort) bool {
+	pterm.Info.Println("🔌 Checking ports and firewall...")
+	ports := append(RequiredPorts(), extra...)
+	firewall := DetectFirewall()
+	checks, err := CheckPorts(ports, firewall)
+	if err != nil {
+		pterm.Warning.Printfln("Cannot read the listening sockets: %v", err)
+		return true
+	}
+
+	pterm.Println()
+	pterm.DefaultSection.Println("🔌 Port Results")
+	if firewall == nil {
+		pterm.Info.Println("No active firewall found (ufw, firewalld, nftables)")
+	} else {
+		pterm.Info.Printfln("Firewall: %s (%s)", firewall.Name, firewall.Summary)
+	}
+	tableData := pterm.TableData{{"Port", "Purpose", "Result", "Listener", "Firewall"}}
+	failed := 0
+	for _, check := range checks {
+		var result string
+		switch check.Level {
+		case LevelOK:
+			result = pterm.Green("pass")
+		case LevelWarning:
+			result = pterm.Yellow("warn")
+		default:
+			result = pterm.Red("fail")
+			failed++
+		}
+		tableData = append(tableData, []string{check.String(), check.Purpose, result, check.Owner, check.Firewall})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	for _, check := range checks {
+		if check.Fix != "" {
+			printer := pterm.Warning
+			if check.Level == LevelCritical {
+				printer = pterm.Error
+			}
+			printer.Printfln("%s: %s", check.String(), check.Fix)
+		}
+	}
+	pterm.Println()
+	return failed == 0
+}
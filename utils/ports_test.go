@@ -0,0 +1,22 @@
+package utils
+
+import "testing"
+
+func TestProcNetAddress(t *testing.T) {
+	tests := map[string]string{
+		"00000000":                         "0.0.0.0",
+		"0100007F":                         "127.0.0.1",
+		"0501A8C0":                         "192.168.1.5",
+		"00000000000000000000000000000000": "[::]",
+		"00000000000000000000000001000000": "[::1]",
+		"B80D0120000000000000000001000000": "[2001:db8::1]",
+		"0000000000000000FFFF00000100007F": "[::ffff:127.0.0.1]",
+		"0100007":                          "0100007",
+		"ZZ00007F":                         "ZZ00007F",
+	}
+	for hex, want := range tests {
+		if got := procNetAddress(hex); got != want {
+			t.Errorf("procNetAddress(%q) = %q, want %q", hex, got, want)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+const gib = 1 << 30
+
+// The checks read the host through these so the tests can fake /proc and
+// the file system sizes.
+var (
+	readHostFile  = os.ReadFile
+	freeDiskSpace = diskFree
+)
+
+// DeploymentSize holds the host requirements of a NETSOCS deployment. Below
+// the Min values the install fails; below the Recommended ones it works
+// with degraded performance.
+type DeploymentSize struct {
+	Name           string
+	MinCPU         int
+	RecommendedCPU int
+	// Memory and disk sizes are in GiB.
+	MinMemory         int
+	RecommendedMemory int
+	MinDockerDisk     int
+	RecommendedDisk   int
+	MinHomeDisk       int
+}
+
+// DeploymentSizes are the sizes accepted by "enviroment --size".
+var DeploymentSizes = []DeploymentSize{
+	{Name: "small", MinCPU: 2, RecommendedCPU: 4, MinMemory: 4, RecommendedMemory: 8, MinDockerDisk: 20, RecommendedDisk: 40, MinHomeDisk: 1},
+	{Name: "medium", MinCPU: 4, RecommendedCPU: 8, MinMemory: 8, RecommendedMemory: 16, MinDockerDisk: 50, RecommendedDisk: 100, MinHomeDisk: 2},
+	{Name: "large", MinCPU: 8, RecommendedCPU: 16, MinMemory: 16, RecommendedMemory: 32, MinDockerDisk: 120, RecommendedDisk: 250, MinHomeDisk: 5},
+}
+
+// DefaultDeploymentSize is used when no size is given.
+const DefaultDeploymentSize = "medium"
+
+// FindDeploymentSize returns the size with the given name.
+func FindDeploymentSize(name string) (DeploymentSize, error) {
+	var names []string
+	for _, size := range DeploymentSizes {
+		if size.Name == name {
+			return size, nil
+		}
+		names = append(names, size.Name)
+	}
+	return DeploymentSize{}, fmt.Errorf("unknown deployment size %q (use %s)", name, strings.Join(names, ", "))
+}
+
+// HostCheck is one finding of the host preflight checks. Level is LevelOK,
+// LevelWarning or LevelCritical, shown as pass, warn and fail.
+type HostCheck struct {
+	Name     string
+	Level    CheckLevel
+	Value    string
+	Required string
+	Fix      string
+}
+
+// sysctlRequirement is a kernel setting Kind or Kubernetes needs.
+type sysctlRequirement struct {
+	key string
+	min int
+}
+
+// Kind runs a kubelet and many watchers per node; the distribution defaults
+// of the inotify limits make pods fail with "too many open files".
+var sysctlRequirements = []sysctlRequirement{
+	{key: "fs.inotify.max_user_watches", min: 524288},
+	{key: "fs.inotify.max_user_instances", min: 512},
+	{key: "net.ipv4.ip_forward", min: 1},
+}
+
+// CheckHost runs the preflight checks of the host for size.
+func CheckHost(size DeploymentSize) []HostCheck {
+	checks := []HostCheck{
+		checkCPU(size),
+		checkMemory(size),
+		checkDisk("Disk (Docker data root)", dockerRootDir(), size.MinDockerDisk, size.RecommendedDisk),
+		checkDisk("Disk (NETSOCS home)", CurrentSettings().Home, size.MinHomeDisk, size.MinHomeDisk*2),
+		checkCgroups(),
+	}
+	for _, requirement := range sysctlRequirements {
+		checks = append(checks, checkSysctl(requirement))
+	}
+	return append(checks, checkSwap(), checkSELinux(), checkAppArmor())
+}
+
+func checkCPU(size DeploymentSize) HostCheck {
+	cpus := runtime.NumCPU()
+	check := HostCheck{
+		Name:     "CPU cores",
+		Value:    strconv.Itoa(cpus),
+		Required: fmt.Sprintf("%d (min %d)", size.RecommendedCPU, size.MinCPU),
+	}
+	switch {
+	case cpus < size.MinCPU:
+		check.Level = LevelCritical
+		check.Fix = fmt.Sprintf("Give the VM at least %d vCPUs", size.MinCPU)
+	case cpus < size.RecommendedCPU:
+		check.Level = LevelWarning
+		check.Fix = fmt.Sprintf("Give the VM %d vCPUs, or use a smaller --size", size.RecommendedCPU)
+	}
+	return check
+}
+
+func checkMemory(size DeploymentSize) HostCheck {
+	check := HostCheck{Name: "Memory", Required: fmt.Sprintf("%d GiB (min %d)", size.RecommendedMemory, size.MinMemory)}
+	total, err := memTotal()
+	if err != nil {
+		check.Level = LevelUnknown
+		check.Value = err.Error()
+		return check
+	}
+	check.Value = formatGiB(total)
+	// Firmware and the kernel reserve part of the RAM, so allow 5%.
+	switch {
+	case float64(total) < float64(size.MinMemory)*gib*0.95:
+		check.Level = LevelCritical
+		check.Fix = fmt.Sprintf("Give the VM at least %d GiB of RAM", size.MinMemory)
+	case float64(total) < float64(size.RecommendedMemory)*gib*0.95:
+		check.Level = LevelWarning
+		check.Fix = fmt.Sprintf("Give the VM %d GiB of RAM, or use a smaller --size", size.RecommendedMemory)
+	}
+	return check
+}
+
+// memTotal returns MemTotal of /proc/meminfo in bytes.
+func memTotal() (uint64, error) {
+	data, err := readHostFile("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("cannot read /proc/meminfo")
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			return kb * 1024, err
+		}
+	}
+	return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+}
+
+// dockerRootDir returns the data root of the Docker daemon, or its default.
+func dockerRootDir() string {
+	output, err := exec.Command("docker", "info", "--format", "{{.DockerRootDir}}").Output()
+	if dir := strings.TrimSpace(string(output)); err == nil && dir != "" {
+		return dir
+	}
+	return "/var/lib/docker"
+}
+
+func checkDisk(name, path string, minGiB, recommendedGiB int) HostCheck {
+	check := HostCheck{Name: name, Required: fmt.Sprintf("%d GiB free (min %d)", recommendedGiB, minGiB)}
+	// The directory may not exist before the install; measure the file
+	// system it will be created on.
+	dir := path
+	for {
+		if _, err := os.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		check.Level = LevelUnknown
+		check.Value = err.Error()
+		return check
+	}
+	check.Value = fmt.Sprintf("%s free on %s", formatGiB(free), path)
+	switch {
+	case free < uint64(minGiB)*gib:
+		check.Level = LevelCritical
+		check.Fix = fmt.Sprintf("Free space or grow the file system of %s; 'docker system prune' removes unused images", path)
+	case free < uint64(recommendedGiB)*gib:
+		check.Level = LevelWarning
+		check.Fix = fmt.Sprintf("Plan for %d GiB free on %s: images and logs grow with every upgrade", recommendedGiB, path)
+	}
+	return check
+}
+
+func checkCgroups() HostCheck {
+	check := HostCheck{Name: "cgroup version", Required: "v2"}
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		check.Value = "v2"
+		return check
+	}
+	if _, err := os.Stat("/sys/fs/cgroup"); err != nil {
+		check.Level = LevelCritical
+		check.Value = "not mounted"
+		check.Fix = "Mount the cgroup file system; containers cannot run without it"
+		return check
+	}
+	check.Level = LevelWarning
+	check.Value = "v1"
+	check.Fix = "Boot with systemd.unified_cgroup_hierarchy=1; recent Kind and Kubernetes releases deprecate cgroup v1"
+	return check
+}
+
+func checkSysctl(requirement sysctlRequirement) HostCheck {
+	check := HostCheck{Name: requirement.key, Required: fmt.Sprintf(">= %d", requirement.min)}
+	path := filepath.Join("/proc/sys", strings.ReplaceAll(requirement.key, ".", "/"))
+	data, err := readHostFile(path)
+	if err != nil {
+		check.Level = LevelUnknown
+		check.Value = "unavailable"
+		return check
+	}
+	check.Value = strings.TrimSpace(string(data))
+	value, err := strconv.Atoi(check.Value)
+	if err != nil || value < requirement.min {
+		check.Level = LevelCritical
+		check.Fix = fmt.Sprintf("echo '%s = %d' | sudo tee -a /etc/sysctl.d/99-netsocs.conf >/dev/null && sudo sysctl --system", requirement.key, requirement.min)
+	}
+	return check
+}
+
+func checkSwap() HostCheck {
+	check := HostCheck{Name: "Swap", Required: "off", Value: "off"}
+	data, err := readHostFile("/proc/swaps")
+	if err != nil {
+		check.Level = LevelUnknown
+		check.Value = "unavailable"
+		return check
+	}
+	// The first line is the header.
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) > 1 {
+		check.Level = LevelWarning
+		check.Value = fmt.Sprintf("on (%d devices)", len(lines)-1)
+		check.Fix = "sudo swapoff -a, and comment out the swap entries of /etc/fstab; swapping makes the services time out"
+	}
+	return check
+}
+
+func checkSELinux() HostCheck {
+	check := HostCheck{Name: "SELinux", Required: "permissive or disabled"}
+	data, err := os.ReadFile("/sys/fs/selinux/enforce")
+	switch {
+	case err != nil:
+		check.Value = "disabled"
+	case strings.TrimSpace(string(data)) == "1":
+		check.Level = LevelWarning
+		check.Value = "enforcing"
+		check.Fix = "Kind volume mounts may be denied: sudo setenforce 0 and set SELINUX=permissive in /etc/selinux/config"
+	default:
+		check.Value = "permissive"
+	}
+	return check
+}
+
+func checkAppArmor() HostCheck {
+	check := HostCheck{Name: "AppArmor", Required: "any", Value: "disabled"}
+	data, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	if err == nil && strings.TrimSpace(string(data)) == "Y" {
+		check.Value = "enabled"
+		// Docker's default profile works with Kind, but a missing
+		// apparmor_parser makes Docker fail to start containers.
+		if _, err := exec.LookPath("apparmor_parser"); err != nil {
+			check.Level = LevelWarning
+			check.Fix = "Install the apparmor package (apparmor_parser), which Docker needs to load its profile"
+		}
+	}
+	return check
+}
+
+func formatGiB(bytes uint64) string {
+	return fmt.Sprintf("%.1f GiB", float64(bytes)/gib)
+}
+
+// CheckHostResources runs and prints the host preflight checks. It returns
+// false when a check fails.
+func CheckHostResources(size DeploymentSize) bool {
+	pterm.Info.Printfln("🖥️  Checking host resources for a %s deployment...", size.Name)
+	checks := CheckHost(size)
+
+	tableData := pterm.TableData{{"Check", "Result", "Value", "Required"}}
+	var fixes []HostCheck
+	failed := 0
+	for _, check := range checks {
+		var result string
+		switch check.Level {
+		case LevelOK:
+			result = pterm.Green("pass")
+		case LevelWarning:
+			result = pterm.Yellow("warn")
+		case LevelCritical:
+			result = pterm.Red("fail")
+			failed++
+		default:
+			result = pterm.Gray("unknown")
+		}
+		tableData = append(tableData, []string{check.Name, result, check.Value, check.Required})
+		if check.Fix != "" {
+			fixes = append(fixes, check)
+		}
+	}
+
+	pterm.Println()
+	pterm.DefaultSection.Println("🖥️  Host Preflight Results")
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	if len(fixes) > 0 {
+		pterm.Println()
+		pterm.DefaultSection.WithLevel(2).Println("Suggested fixes")
+		for _, check := range fixes {
+			printer := pterm.Warning
+			if check.Level == LevelCritical {
+				printer = pterm.Error
+			}
+			printer.Printfln("%s: %s", check.Name, check.Fix)
+		}
+	}
+	pterm.Println()
+	return failed == 0
+}
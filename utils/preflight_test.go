@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeHostFiles makes readHostFile serve files from the map; other paths do
+// not exist.
+func fakeHostFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	previous := readHostFile
+	readHostFile = func(path string) ([]byte, error) {
+		if data, ok := files[path]; ok {
+			return []byte(data), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	t.Cleanup(func() { readHostFile = previous })
+}
+
+func TestCheckMemory(t *testing.T) {
+	size, _ := FindDeploymentSize("medium")
+	// 95% of 8 GiB is 7969177.6 kB and 95% of 16 GiB is 15938355.2 kB.
+	tests := []struct {
+		name    string
+		meminfo string
+		want    CheckLevel
+	}{
+		{"recommended", "MemTotal:       16777216 kB\n", LevelOK},
+		{"within 5% of recommended", "MemTotal:       15938356 kB\n", LevelOK},
+		{"just below 5% of recommended", "MemTotal:       15938355 kB\n", LevelWarning},
+		{"within 5% of minimum", "MemFree: 1 kB\nMemTotal: 7969178 kB\n", LevelWarning},
+		{"just below 5% of minimum", "MemTotal: 7969177 kB\n", LevelCritical},
+		{"no MemTotal", "MemFree: 1 kB\n", LevelUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeHostFiles(t, map[string]string{"/proc/meminfo": tt.meminfo})
+			if got := checkMemory(size); got.Level != tt.want {
+				t.Errorf("level = %v (%s), want %v", got.Level, got.Value, tt.want)
+			}
+		})
+	}
+
+	fakeHostFiles(t, nil)
+	if got := checkMemory(size); got.Level != LevelUnknown {
+		t.Errorf("without /proc/meminfo level = %v, want unknown", got.Level)
+	}
+}
+
+func TestCheckDisk(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		free uint64
+		err  error
+		want CheckLevel
+	}{
+		{"recommended", 100 * gib, nil, LevelOK},
+		{"below recommended", 100*gib - 1, nil, LevelWarning},
+		{"minimum", 50 * gib, nil, LevelWarning},
+		{"below minimum", 50*gib - 1, nil, LevelCritical},
+		{"statfs error", 0, errors.New("statfs failed"), LevelUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var measured string
+			previous := freeDiskSpace
+			freeDiskSpace = func(path string) (uint64, error) {
+				measured = path
+				return tt.free, tt.err
+			}
+			defer func() { freeDiskSpace = previous }()
+
+			// A directory that does not exist yet is measured on its
+			// closest existing parent.
+			got := checkDisk("Disk", filepath.Join(dir, "missing", "home"), 50, 100)
+			if got.Level != tt.want {
+				t.Errorf("level = %v (%s), want %v", got.Level, got.Value, tt.want)
+			}
+			if measured != dir {
+				t.Errorf("measured %s, want %s", measured, dir)
+			}
+		})
+	}
+}
+
+func TestCheckSysctl(t *testing.T) {
+	requirement := sysctlRequirement{key: "fs.inotify.max_user_watches", min: 524288}
+	path := "/proc/sys/fs/inotify/max_user_watches"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  CheckLevel
+		value string
+	}{
+		{"at minimum", map[string]string{path: "524288\n"}, LevelOK, "524288"},
+		{"above minimum", map[string]string{path: "1048576"}, LevelOK, "1048576"},
+		{"below minimum", map[string]string{path: "8192\n"}, LevelCritical, "8192"},
+		{"not a number", map[string]string{path: "unlimited\n"}, LevelCritical, "unlimited"},
+		{"missing", nil, LevelUnknown, "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeHostFiles(t, tt.files)
+			got := checkSysctl(requirement)
+			if got.Level != tt.want || got.Value != tt.value {
+				t.Errorf("got %v %q, want %v %q", got.Level, got.Value, tt.want, tt.value)
+			}
+			if (got.Level == LevelCritical) != (got.Fix != "") {
+				t.Errorf("fix = %q for level %v", got.Fix, got.Level)
+			}
+		})
+	}
+}
+
+func TestCheckSwap(t *testing.T) {
+	const header = "Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  CheckLevel
+		value string
+	}{
+		{"header only", map[string]string{"/proc/swaps": header}, LevelOK, "off"},
+		{"empty", map[string]string{"/proc/swaps": ""}, LevelOK, "off"},
+		{"one device", map[string]string{"/proc/swaps": header + "/swap.img\tfile\t2097148\t0\t-2\n"}, LevelWarning, "on (1 devices)"},
+		{"two devices", map[string]string{"/proc/swaps": header + "/dev/sda2\tpartition\t1048572\t0\t-2\n/swap.img\tfile\t2097148\t0\t-3\n"}, LevelWarning, "on (2 devices)"},
+		{"missing", nil, LevelUnknown, "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeHostFiles(t, tt.files)
+			got := checkSwap()
+			if got.Level != tt.want || got.Value != tt.value {
+				t.Errorf("got %v %q, want %v %q", got.Level, got.Value, tt.want, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	proxySettingsPath = "proxy"
+	// proxyDropIn is the systemd drop-in written for the Docker daemon on the
+	// host and for containerd in the Kind nodes.
+	proxyDropIn         = "netsocs-proxy.conf"
+	dockerDropInDir     = "/etc/systemd/system/docker.service.d"
+	containerdDropInDir = "/etc/systemd/system/containerd.service.d"
+	kindNodeLabel       = "io.x-k8s.kind.cluster"
+)
+
+// DefaultNoProxy keeps local and in-cluster traffic off the proxy: the Kind
+// API server on localhost, the service and pod ranges of Kind, and the
+// cluster DNS names.
+var DefaultNoProxy = []string{
+	"localhost", "127.0.0.1", "::1",
+	"10.96.0.0/12", "10.244.0.0/16",
+	".svc", ".cluster.local",
+}
+
+// ProxySettings is the "proxy" section of the CLI config file.
+type ProxySettings struct {
+	HTTP    string   `yaml:"http,omitempty"`
+	HTTPS   string   `yaml:"https,omitempty"`
+	NoProxy []string `yaml:"noProxy,omitempty"`
+}
+
+// Enabled reports whether a proxy is configured.
+func (p ProxySettings) Enabled() bool {
+	return p.HTTP != "" || p.HTTPS != ""
+}
+
+// Env returns the proxy variables, in both cases since tools disagree on
+// which one they read.
+func (p ProxySettings) Env() []string {
+	var env []string
+	add := func(name, value string) {
+		if value != "" {
+			env = append(env, name+"="+value, strings.ToLower(name)+"="+value)
+		}
+	}
+	add("HTTP_PROXY", p.HTTP)
+	add("HTTPS_PROXY", p.HTTPS)
+	add("NO_PROXY", strings.Join(p.NoProxy, ","))
+	return env
+}
+
+// exportProxyEnv sets the proxy variables for the CLI's HTTP client and every
+// tool it runs. Variables already in the environment take precedence.
+func (p ProxySettings) exportProxyEnv() {
+	for _, entry := range p.Env() {
+		name, value, _ := strings.Cut(entry, "=")
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// ParseProxyURL checks a proxy URL, adding http:// when no scheme is given.
+func ParseProxyURL(raw string) (string, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return "", fmt.Errorf("unsupported proxy scheme %q, use http, https or socks5", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("missing proxy host")
+	}
+	return u.String(), nil
+}
+
+// RedactProxyURL hides the password of a proxy URL.
+func RedactProxyURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// NewProxySettings builds the settings for a proxy URL, adding noProxy and
+// the configured host to the defaults.
+func NewProxySettings(httpURL, httpsURL string, noProxy []string) ProxySettings {
+	if httpsURL == "" {
+		httpsURL = httpURL
+	}
+	seen := map[string]bool{}
+	var entries []string
+	add := func(values ...string) {
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value != "" && !seen[value] {
+				seen[value] = true
+				entries = append(entries, value)
+			}
+		}
+	}
+	add(DefaultNoProxy...)
+	add(ConfiguredHost())
+	add(noProxy...)
+	return ProxySettings{HTTP: httpURL, HTTPS: httpsURL, NoProxy: entries}
+}
+
+// SaveProxySettings stores proxy in the CLI config file, or removes the
+// section when proxy is not enabled.
+func SaveProxySettings(proxy ProxySettings) error {
+	path, err := SettingsFilePath()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	doc, err := parseValuesDocument(original)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if proxy.Enabled() {
+		err = SetNodeValue(doc, proxySettingsPath, proxy)
+	} else {
+		_, err = UnsetNodeValue(doc, proxySettingsPath)
+	}
+	if err != nil {
+		return err
+	}
+	content, err := EncodeValuesDocument(doc, original)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// The proxy URL may carry credentials.
+	return writeFileAtomic(path, content, 0600)
+}
+
+// unitFileEscaper quotes a value for a double-quoted systemd setting, where
+// backslash starts a C-style escape and "%" a specifier.
+var unitFileEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%")
+
+// proxyDropInContent renders a systemd drop-in setting the proxy variables.
+func proxyDropInContent(proxy ProxySettings) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Written by netsocs proxy set\n[Service]\n")
+	for _, entry := range proxy.Env() {
+		fmt.Fprintf(&buf, "Environment=\"%s\"\n", unitFileEscaper.Replace(entry))
+	}
+	return buf.Bytes()
+}
+
+// privileged returns a command run as root, through sudo when needed.
+func privileged(name string, args ...string) *exec.Cmd {
+	if os.Geteuid() == 0 {
+		return exec.Command(name, args...)
+	}
+	return exec.Command("sudo", append([]string{name}, args...)...)
+}
+
+// ApplyDockerProxy writes the proxy drop-in of the Docker daemon, or removes
+// it when proxy is not enabled. The daemon only reads it when restarted,
+// which also stops the Kind nodes for a moment, so restart is optional.
+func ApplyDockerProxy(proxy ProxySettings, restart bool) error {
+	path := filepath.Join(dockerDropInDir, proxyDropIn)
+	var script string
+	if proxy.Enabled() {
+		script = fmt.Sprintf("mkdir -p %s && umask 077 && cat > %s", dockerDropInDir, path)
+	} else {
+		script = fmt.Sprintf("rm -f %s", path)
+	}
+	script += " && systemctl daemon-reload"
+	if restart {
+		script += " && systemctl restart docker"
+	}
+
+	cmd := privileged("sh", "-c", script)
+	cmd.Stdin = bytes.NewReader(proxyDropInContent(proxy))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error configuring the Docker daemon: %w", err)
+	}
+	return nil
+}
+
+// KindNodes returns the containers of the Kind nodes.
+func KindNodes() ([]string, error) {
+	output, err := exec.Command("docker", "ps", "--filter", "label="+kindNodeLabel, "--format", "{{.Names}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Kind nodes: %s", commandError(err))
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// ApplyKindProxy configures containerd in every Kind node so image pulls go
+// through the proxy. Running containers are not affected by the restart.
+func ApplyKindProxy(proxy ProxySettings) error {
+	nodes, err := KindNodes()
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		pterm.Info.Println("No Kind nodes found")
+		return nil
+	}
+
+	path := filepath.Join(containerdDropInDir, proxyDropIn)
+	script := fmt.Sprintf("mkdir -p %s && umask 077 && cat > %s", containerdDropInDir, path)
+	if !proxy.Enabled() {
+		script = fmt.Sprintf("rm -f %s", path)
+	}
+	script += " && systemctl daemon-reload && systemctl restart containerd"
+
+	if proxy.Enabled() {
+		// The nodes talk to each other by name.
+		proxy.NoProxy = append(append([]string{}, proxy.NoProxy...), nodes...)
+	}
+	content := proxyDropInContent(proxy)
+	for _, node := range nodes {
+		cmd := exec.Command("docker", "exec", "--interactive", node, "sh", "-c", script)
+		cmd.Stdin = bytes.NewReader(content)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("error configuring containerd in %s: %s", node, strings.TrimSpace(string(output)))
+		}
+		pterm.Success.Printfln("containerd in %s configured", node)
+	}
+	return nil
+}
+
+// ProxyStatus describes where the proxy is applied.
+type ProxyStatus struct {
+	Settings ProxySettings
+	// Env is the proxy environment of the CLI process, which wins over the
+	// settings for the CLI and the tools it runs.
+	Env map[string]string
+	// Docker and Kind hold the drop-in found, or an empty string.
+	Docker string
+	Kind   map[string]string
+}
+
+// GetProxyStatus collects the proxy settings and what is applied to Docker
+// and the Kind nodes. Unreachable components are left out.
+func GetProxyStatus() ProxyStatus {
+	status := ProxyStatus{Settings: CurrentSettings().Proxy, Env: map[string]string{}, Kind: map[string]string{}}
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		if value := os.Getenv(name); value != "" {
+			status.Env[name] = value
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(dockerDropInDir, proxyDropIn)); err == nil {
+		status.Docker = string(content)
+	}
+	if nodes, err := KindNodes(); err == nil {
+		for _, node := range nodes {
+			output, _ := exec.Command("docker", "exec", node, "cat", filepath.Join(containerdDropInDir, proxyDropIn)).Output()
+			status.Kind[node] = string(output)
+		}
+	}
+	return status
+}
+
+// DescribeProxy returns the proxy used for HTTPS requests by the CLI, or an
+// empty string when there is none.
+func DescribeProxy() string {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			return RedactProxyURL(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,471 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	CLIRepo        = "Netsocs-Team/netsocs-cli"
+	CLIBinaryName  = "netsocs"
+	ReleaseIndex   = "index.json"
+	githubAPIURL   = "https://api.github.com"
+	maxRateLimWait = 30 * time.Second
+)
+
+// CLIRelease describes a published CLI binary.
+type CLIRelease struct {
+	Version    string `json:"version"`
+	URL        string `json:"url"`
+	SHA256     string `json:"sha256,omitempty"`
+	Security   bool   `json:"security,omitempty"`
+	Prerelease bool   `json:"prerelease,omitempty"`
+}
+
+// ReleaseSource is where the CLI looks for its own releases. Releases are
+// returned newest first.
+type ReleaseSource interface {
+	Name() string
+	Releases() ([]CLIRelease, error)
+	Open(release CLIRelease) (io.ReadCloser, error)
+}
+
+// releaseIndex is the format of index.json served by mirrors and local
+// directories.
+type releaseIndex struct {
+	Releases []CLIRelease `json:"releases"`
+}
+
+// NewReleaseSource builds a release source from a spec: "github" (default),
+// an http(s) URL pointing to a mirror, or a local directory such as a USB
+// mount. An empty spec falls back to NETSOCS_RELEASE_SOURCE.
+func NewReleaseSource(spec string) (ReleaseSource, error) {
+	if spec == "" {
+		spec = os.Getenv("NETSOCS_RELEASE_SOURCE")
+	}
+
+	switch {
+	case spec == "" || spec == "github":
+		token := os.Getenv("NETSOCS_GITHUB_TOKEN")
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		return &githubReleaseSource{
+			repo:   CLIRepo,
+			token:  token,
+			client: NewHTTPClient(30 * time.Second),
+		}, nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		base, err := url.Parse(strings.TrimSuffix(spec, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror URL %q: %w", spec, err)
+		}
+		return &mirrorReleaseSource{
+			base:   base,
+			client: NewHTTPClient(60 * time.Second),
+		}, nil
+	default:
+		dir := strings.TrimPrefix(spec, "file://")
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("release directory %s is not accessible: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("release source %s is not a directory", dir)
+		}
+		return &localReleaseSource{dir: dir}, nil
+	}
+}
+
+// githubReleaseSource reads releases from the GitHub REST API.
+type githubReleaseSource struct {
+	repo   string
+	token  string
+	client *http.Client
+}
+
+func (s *githubReleaseSource) Name() string {
+	return "github.com/" + s.repo
+}
+
+func (s *githubReleaseSource) Releases() ([]CLIRelease, error) {
+	resp, err := s.get(fmt.Sprintf("%s/repos/%s/releases?per_page=30", githubAPIURL, s.repo))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data []struct {
+		TagName    string `json:"tag_name"`
+		Name       string `json:"name"`
+		Body       string `json:"body"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+		Assets     []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding GitHub releases: %w", err)
+	}
+
+	var result []CLIRelease
+	for _, rel := range data {
+		if rel.Draft {
+			continue
+		}
+		release := CLIRelease{
+			Version:    rel.TagName,
+			Prerelease: rel.Prerelease,
+			Security:   isSecurityRelease(rel.Name + "\n" + rel.Body),
+		}
+		for _, asset := range rel.Assets {
+			if asset.Name == CLIBinaryName {
+				release.URL = asset.BrowserDownloadURL
+				break
+			}
+		}
+		if release.URL == "" {
+			for _, asset := range rel.Assets {
+				if isCurrentPlatformAsset(asset.Name) {
+					release.URL = asset.BrowserDownloadURL
+					break
+				}
+			}
+		}
+		result = append(result, release)
+	}
+	return result, nil
+}
+
+func (s *githubReleaseSource) Open(release CLIRelease) (io.ReadCloser, error) {
+	if release.URL == "" {
+		return nil, fmt.Errorf("no suitable binary found for version %s", release.Version)
+	}
+	req, err := http.NewRequest(http.MethodGet, release.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading %s returned HTTP %d", release.URL, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// get performs an API request, retrying once when GitHub asks for a short
+// back-off and turning rate-limit responses into an actionable error.
+func (s *githubReleaseSource) get(apiURL string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("User-Agent", userAgent)
+		if s.token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.token)
+		}
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, fmt.Errorf("GitHub API returned HTTP %d for %s", resp.StatusCode, apiURL)
+		}
+
+		wait := rateLimitWait(resp.Header)
+		if attempt == 0 && wait > 0 && wait <= maxRateLimWait {
+			pterm.Warning.Printfln("GitHub rate limit reached, retrying in %s...", wait.Round(time.Second))
+			time.Sleep(wait)
+			continue
+		}
+
+		hint := "set NETSOCS_GITHUB_TOKEN to raise the limit or use --source with a mirror"
+		if s.token != "" {
+			hint = "check that the token in NETSOCS_GITHUB_TOKEN is valid"
+		}
+		if wait > 0 {
+			return nil, fmt.Errorf("GitHub API rate limit exceeded, resets in %s (%s)", wait.Round(time.Second), hint)
+		}
+		return nil, fmt.Errorf("GitHub API returned HTTP %d (%s)", resp.StatusCode, hint)
+	}
+}
+
+// rateLimitWait returns how long GitHub asks us to wait, based on Retry-After
+// or on an exhausted X-RateLimit-Remaining with its reset timestamp.
+func rateLimitWait(header http.Header) time.Duration {
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if wait := time.Until(time.Unix(reset, 0)); wait > 0 {
+				return wait
+			}
+		}
+	}
+	return 0
+}
+
+// mirrorReleaseSource reads releases from an HTTPS mirror that serves an
+// index.json next to the binaries.
+type mirrorReleaseSource struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func (s *mirrorReleaseSource) Name() string {
+	return s.base.String()
+}
+
+func (s *mirrorReleaseSource) Releases() ([]CLIRelease, error) {
+	body, err := s.fetch(s.base.ResolveReference(&url.URL{Path: ReleaseIndex}).String())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var index releaseIndex
+	if err := json.NewDecoder(body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("error decoding mirror index: %w", err)
+	}
+	for i := range index.Releases {
+		ref, err := url.Parse(index.Releases[i].URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL for version %s: %w", index.Releases[i].Version, err)
+		}
+		index.Releases[i].URL = s.base.ResolveReference(ref).String()
+	}
+	sortReleases(index.Releases)
+	return index.Releases, nil
+}
+
+func (s *mirrorReleaseSource) Open(release CLIRelease) (io.ReadCloser, error) {
+	return s.fetch(release.URL)
+}
+
+func (s *mirrorReleaseSource) fetch(target string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("mirror returned HTTP %d for %s", resp.StatusCode, target)
+	}
+	return resp.Body, nil
+}
+
+// localReleaseSource reads releases from a directory, either through an
+// index.json or from a <version>/netsocs layout.
+type localReleaseSource struct {
+	dir string
+}
+
+func (s *localReleaseSource) Name() string {
+	return s.dir
+}
+
+func (s *localReleaseSource) Releases() ([]CLIRelease, error) {
+	indexPath := filepath.Join(s.dir, ReleaseIndex)
+	if data, err := os.ReadFile(indexPath); err == nil {
+		var index releaseIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %w", indexPath, err)
+		}
+		// Relative paths are relative to the directory; absolute paths and
+		// URLs such as https://... are used as they are.
+		for i := range index.Releases {
+			if target := index.Releases[i].URL; !filepath.IsAbs(target) && !hasURLScheme(target) {
+				index.Releases[i].URL = filepath.Join(s.dir, target)
+			}
+		}
+		sortReleases(index.Releases)
+		return index.Releases, nil
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.dir, err)
+	}
+	var result []CLIRelease
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		binPath := filepath.Join(s.dir, entry.Name(), CLIBinaryName)
+		if _, err := os.Stat(binPath); err != nil {
+			continue
+		}
+		result = append(result, CLIRelease{Version: entry.Name(), URL: binPath})
+	}
+	sortReleases(result)
+	return result, nil
+}
+
+func (s *localReleaseSource) Open(release CLIRelease) (io.ReadCloser, error) {
+	if strings.HasPrefix(release.URL, "http://") || strings.HasPrefix(release.URL, "https://") {
+		mirror := &mirrorReleaseSource{client: NewHTTPClient(60 * time.Second)}
+		return mirror.fetch(release.URL)
+	}
+	return os.Open(strings.TrimPrefix(release.URL, "file://"))
+}
+
+func hasURLScheme(target string) bool {
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Scheme != ""
+}
+
+func sortReleases(releases []CLIRelease) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		return CompareVersions(releases[i].Version, releases[j].Version) > 0
+	})
+}
+
+func isSecurityRelease(text string) bool {
+	text = strings.ToLower(text)
+	return strings.Contains(text, "[security]") || strings.Contains(text, "security release")
+}
+
+// FindCLIRelease returns the requested release, or the newest stable one when
+// version is empty.
+func FindCLIRelease(source ReleaseSource, version string) (CLIRelease, error) {
+	releases, err := source.Releases()
+	if err != nil {
+		return CLIRelease{}, err
+	}
+	for _, rel := range releases {
+		if version == "" && !rel.Prerelease {
+			return rel, nil
+		}
+		if version != "" && NormalizeVersion(rel.Version) == NormalizeVersion(version) {
+			return rel, nil
+		}
+	}
+	if version == "" {
+		return CLIRelease{}, fmt.Errorf("no releases found in %s", source.Name())
+	}
+	return CLIRelease{}, fmt.Errorf("version %s not found in %s", version, source.Name())
+}
+
+func ListAvailableCLIVersions(source ReleaseSource) ([]string, error) {
+	releases, err := source.Releases()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for i, rel := range releases {
+		if i >= 10 {
+			break
+		}
+		result = append(result, rel.Version)
+	}
+	return result, nil
+}
+
+func DownloadAndReplaceCLI(source ReleaseSource, release CLIRelease) error {
+	pterm.Info.Printfln("Downloading CLI %s from %s", release.Version, source.Name())
+
+	// Download binary to <home>/netsocs.new
+	netsocsDir := CurrentSettings().Home
+	if err := os.MkdirAll(netsocsDir, 0755); err != nil {
+		return err
+	}
+	newBinPath := filepath.Join(netsocsDir, "netsocs.new")
+	body, err := source.Open(release)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	f, err := os.OpenFile(newBinPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), body); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	if release.SHA256 != "" {
+		if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, release.SHA256) {
+			os.Remove(newBinPath)
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", release.Version, release.SHA256, sum)
+		}
+		pterm.Success.Println("Checksum verified")
+	}
+
+	// Create update.sh script
+	updateScriptPath := filepath.Join(netsocsDir, "update.sh")
+	updateScript := fmt.Sprintf(`#!/bin/bash
+set -e
+echo "Updating CLI..."
+sudo rm /usr/local/bin/netsocs
+sudo cp "%s" /usr/local/bin/netsocs
+sudo chmod +x /usr/local/bin/netsocs
+echo "Update complete!"
+`, newBinPath)
+	if err := os.WriteFile(updateScriptPath, []byte(updateScript), 0755); err != nil {
+		return err
+	}
+
+	pterm.Info.Println("Nuevo binario descargado en:", newBinPath)
+	pterm.Info.Println("Ejecutando script de actualización en segundo plano:", updateScriptPath)
+
+	// Ejecutar el script de forma asíncrona
+	cmd := exec.Command("bash", updateScriptPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Iniciar el comando en segundo plano sin esperar
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error iniciando el script de actualización: %w", err)
+	}
+
+	// No esperar a que termine, solo informar que se inició
+	pterm.Success.Println("Actualización iniciada en segundo plano. El CLI se actualizará automáticamente.")
+
+	return nil
+}
+
+func isCurrentPlatformAsset(name string) bool {
+	osName := runtime.GOOS
+	arch := runtime.GOARCH
+	return strings.Contains(name, osName) && strings.Contains(name, arch)
+}
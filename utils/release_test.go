@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalReleaseSourceIndexURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "remote binary")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	absolute := filepath.Join(t.TempDir(), "netsocs")
+	if err := os.WriteFile(absolute, []byte("absolute binary"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "1.0.0"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "1.0.0", "netsocs"), []byte("local binary"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	index := `{"releases": [
+		{"version": "1.0.0", "url": "1.0.0/netsocs"},
+		{"version": "1.1.0", "url": "` + absolute + `"},
+		{"version": "1.2.0", "url": "` + server.URL + `/1.2.0/netsocs"},
+		{"version": "1.3.0", "url": "file://` + absolute + `"}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, ReleaseIndex), []byte(index), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &localReleaseSource{dir: dir}
+	releases, err := source.Releases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct{ url, content string }{
+		"1.0.0": {filepath.Join(dir, "1.0.0", "netsocs"), "local binary"},
+		"1.1.0": {absolute, "absolute binary"},
+		"1.2.0": {server.URL + "/1.2.0/netsocs", "remote binary"},
+		"1.3.0": {"file://" + absolute, "absolute binary"},
+	}
+	if len(releases) != len(want) {
+		t.Fatalf("got %d releases, want %d", len(releases), len(want))
+	}
+	for _, release := range releases {
+		expected := want[release.Version]
+		if release.URL != expected.url {
+			t.Errorf("%s: URL = %q, want %q", release.Version, release.URL, expected.url)
+			continue
+		}
+		body, err := source.Open(release)
+		if err != nil {
+			t.Errorf("%s: open: %v", release.Version, err)
+			continue
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != expected.content {
+			t.Errorf("%s: content = %q, want %q", release.Version, data, expected.content)
+		}
+	}
+}
@@ -0,0 +1,523 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ValidationIssue is a problem found in values.yaml.
+type ValidationIssue struct {
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// ChartSpec holds what is needed to validate values against a chart version.
+type ChartSpec struct {
+	Version  string
+	Defaults map[string]interface{}
+	Schema   map[string]interface{}
+}
+
+// FetchChartSpec returns the default values and values.schema.json, if the
+// chart has one, of a chart version (the latest in the local repo index when
+// version is empty). Each version is downloaded once and then read from the
+// cache under the NETSOCS home directory.
+func FetchChartSpec(version string) (*ChartSpec, error) {
+	if version == "" {
+		versions, err := ListAvailableAppVersions()
+		if err != nil || len(versions) == 0 {
+			return nil, fmt.Errorf("could not find the latest chart version, run 'helm repo update'")
+		}
+		version = versions[0]
+	}
+
+	dir := chartSpecCacheDir(version)
+	if _, err := os.Stat(filepath.Join(dir, "values.yaml")); err != nil {
+		if err := pullChartSpec(version, dir); err != nil {
+			return nil, err
+		}
+	}
+	return readChartSpec(version, dir)
+}
+
+func chartSpecCacheDir(version string) string {
+	s := CurrentSettings()
+	return filepath.Join(s.Home, "cache", "charts", s.RepoName(), s.ChartName()+"-"+version)
+}
+
+// pullChartSpec downloads a chart version and keeps its values.yaml and
+// values.schema.json in dir. values.yaml is written last, so a cache entry
+// with it is complete.
+func pullChartSpec(version, dir string) error {
+	tmpDir, err := os.MkdirTemp("", "netsocs-chart-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cmd := HelmCommand("pull", CurrentSettings().Chart, "--untar", "--untardir", tmpDir, "--version", version)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error downloading chart: %s", strings.TrimSpace(string(output)))
+	}
+
+	chartDir := filepath.Join(tmpDir, CurrentSettings().ChartName())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating chart cache: %w", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(chartDir, "values.schema.json")); err == nil {
+		if err := writeFileAtomic(filepath.Join(dir, "values.schema.json"), data, 0644); err != nil {
+			return fmt.Errorf("error caching chart schema: %w", err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading chart defaults: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "values.yaml"), data, 0644); err != nil {
+		return fmt.Errorf("error caching chart defaults: %w", err)
+	}
+	return nil
+}
+
+func readChartSpec(version, dir string) (*ChartSpec, error) {
+	spec := &ChartSpec{Version: version, Defaults: map[string]interface{}{}}
+	data, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading chart defaults: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &spec.Defaults); err != nil {
+		return nil, fmt.Errorf("error decoding chart defaults: %w", err)
+	}
+	if spec.Defaults == nil {
+		spec.Defaults = map[string]interface{}{}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "values.schema.json")); err == nil {
+		if err := json.Unmarshal(data, &spec.Schema); err != nil {
+			return nil, fmt.Errorf("error decoding values.schema.json: %w", err)
+		}
+	}
+	return spec, nil
+}
+
+// ValidateValues checks values against the chart schema and reports keys
+// that do not exist in the chart defaults, with "did you mean" suggestions.
+// Encrypted secrets are checked in plaintext when the secret key is
+// available and skipped otherwise.
+func ValidateValues(values map[string]interface{}, spec *ChartSpec) []ValidationIssue {
+	if decrypted, _, err := DecryptValues(values); err == nil {
+		values = decrypted
+	}
+	var issues []ValidationIssue
+	if spec.Schema != nil {
+		// Helm validates the merged values, so defaults fill in required keys.
+		merged := MergeValues(spec.Defaults, values)
+		v := schemaValidator{root: spec.Schema}
+		issues = append(issues, v.validate(spec.Schema, toJSONValue(merged), "")...)
+	}
+	reported := map[string]bool{}
+	for _, issue := range issues {
+		reported[issue.Path] = true
+	}
+	for _, issue := range unknownKeys(values, spec.Defaults, spec.Schema, "") {
+		if !reported[issue.Path] {
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Severity != issues[j].Severity {
+			return issues[i].Severity == SeverityError
+		}
+		return issues[i].Path < issues[j].Path
+	})
+	return issues
+}
+
+// ValidateValuesFile validates the merged values layers against the given
+// chart version and prints the result. It returns an error when there are
+// schema errors.
+func ValidateValuesFile(version string) error {
+	values, err := LoadLayeredValues(nil)
+	if err != nil {
+		return err
+	}
+	return ValidateAndReport(values, version)
+}
+
+// ValidateAndReport validates values against the given chart version, prints
+// any issue and returns an error when there are schema errors.
+func ValidateAndReport(values map[string]interface{}, version string) error {
+	spec, err := FetchChartSpec(version)
+	if err != nil {
+		return fmt.Errorf("could not load chart for validation: %w", err)
+	}
+	return reportValidation(values, spec)
+}
+
+func reportValidation(values map[string]interface{}, spec *ChartSpec) error {
+	issues := ValidateValues(values, spec)
+	PrintValidationIssues(issues)
+
+	errorsFound := 0
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errorsFound++
+		}
+	}
+	if errorsFound > 0 {
+		return fmt.Errorf("values.yaml has %d validation error(s)", errorsFound)
+	}
+	if spec.Schema == nil {
+		pterm.Debug.Println("Chart has no values.schema.json, only unknown keys were checked")
+	}
+	return nil
+}
+
+// ValidateBeforeApply is used by commands that change or deploy values. It
+// only warns when the chart cannot be downloaded so that offline changes are
+// still possible, and does nothing when skip is set.
+func ValidateBeforeApply(values map[string]interface{}, version string, skip bool) error {
+	if skip {
+		pterm.Warning.Println("Skipping values validation")
+		return nil
+	}
+	spec, err := FetchChartSpec(version)
+	if err != nil {
+		pterm.Warning.Printfln("Could not validate values: %v", err)
+		return nil
+	}
+	if err := reportValidation(values, spec); err != nil {
+		return fmt.Errorf("%w (use --skip-validation to apply anyway)", err)
+	}
+	return nil
+}
+
+func PrintValidationIssues(issues []ValidationIssue) {
+	for _, issue := range issues {
+		path := issue.Path
+		if path == "" {
+			path = "(root)"
+		}
+		if issue.Severity == SeverityError {
+			pterm.Error.Printfln("%s: %s", path, issue.Message)
+		} else {
+			pterm.Warning.Printfln("%s: %s", path, issue.Message)
+		}
+	}
+}
+
+// unknownKeys walks values alongside the chart defaults. Keys under an empty
+// default map (e.g. podAnnotations: {}) are free-form and not reported, and
+// neither are keys declared in the schema.
+func unknownKeys(values, defaults, schema map[string]interface{}, prefix string) []ValidationIssue {
+	var issues []ValidationIssue
+	if len(defaults) == 0 {
+		return nil
+	}
+	known := make([]string, 0, len(defaults))
+	for key := range defaults {
+		known = append(known, key)
+	}
+
+	for key, value := range values {
+		path := joinPath(prefix, key)
+		childSchema := schemaProperty(schema, key)
+		def, exists := defaults[key]
+		if !exists {
+			if childSchema != nil {
+				continue
+			}
+			message := "unknown key, not present in the chart defaults"
+			if suggestion := closestKey(key, known); suggestion != "" {
+				message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+			}
+			issues = append(issues, ValidationIssue{Path: path, Message: message, Severity: SeverityWarning})
+			continue
+		}
+		valueMap, valueIsMap := value.(map[string]interface{})
+		defMap, defIsMap := def.(map[string]interface{})
+		if valueIsMap && defIsMap {
+			issues = append(issues, unknownKeys(valueMap, defMap, childSchema, path)...)
+		}
+	}
+	return issues
+}
+
+func schemaProperty(schema map[string]interface{}, key string) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	property, _ := properties[key].(map[string]interface{})
+	return property
+}
+
+func closestKey(key string, candidates []string) string {
+	best, bestDistance := "", math.MaxInt
+	for _, candidate := range candidates {
+		distance := fuzzy.LevenshteinDistance(strings.ToLower(key), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance <= 2 || bestDistance <= len(key)/3 {
+		return best
+	}
+	return ""
+}
+
+// toJSONValue normalizes YAML-decoded values to the types produced by
+// encoding/json so that schema type checks behave the same for both.
+func toJSONValue(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return value
+	}
+	return result
+}
+
+// schemaValidator implements the subset of JSON Schema used by Helm chart
+// schemas: type, enum, const, properties, required, additionalProperties,
+// items, numeric and length bounds, pattern, $ref and the allOf/anyOf/oneOf
+// combinators.
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+func (v schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) []ValidationIssue {
+	// The plaintext of a secret that could not be decrypted is unknown.
+	if IsEncrypted(value) {
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return []ValidationIssue{{Path: path, Message: err.Error(), Severity: SeverityWarning}}
+		}
+		schema = resolved
+	}
+
+	var issues []ValidationIssue
+	fail := func(format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{Path: path, Message: fmt.Sprintf(format, args...), Severity: SeverityError})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesType(value, types) {
+		fail("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(value))
+		return issues
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		fail("must be one of %s", FormatValue(enum))
+	}
+	if constant, ok := schema["const"]; ok && FormatValue(constant) != FormatValue(value) {
+		fail("must be %s", FormatValue(constant))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		issues = append(issues, v.validateObject(schema, val, path)...)
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(val)) < min {
+			fail("must have at least %v items", min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(val)) > max {
+			fail("must have at most %v items", max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				issues = append(issues, v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		length := float64(len([]rune(val)))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			fail("must be at least %v characters", min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			fail("must be at most %v characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(val) {
+				fail("must match pattern %s", pattern)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && val < min {
+			fail("must be >= %v", min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && val > max {
+			fail("must be <= %v", max)
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && val <= min {
+			fail("must be > %v", min)
+		}
+		if max, ok := schema["exclusiveMaximum"].(float64); ok && val >= max {
+			fail("must be < %v", max)
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				issues = append(issues, v.validate(subSchema, value, path)...)
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && v.countMatches(anyOf, value, path) == 0 {
+		fail("does not match any of the allowed schemas")
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok && v.countMatches(oneOf, value, path) != 1 {
+		fail("must match exactly one of the allowed schemas")
+	}
+	return issues
+}
+
+func (v schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) []ValidationIssue {
+	var issues []ValidationIssue
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, exists := value[key]; !exists {
+				issues = append(issues, ValidationIssue{Path: joinPath(path, key), Message: "required key is missing", Severity: SeverityError})
+			}
+		}
+	}
+
+	for key, child := range value {
+		childPath := joinPath(path, key)
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			issues = append(issues, v.validate(propSchema, child, childPath)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				message := "key is not allowed by the chart schema"
+				if suggestion := closestKey(key, mapKeys(properties)); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+				}
+				issues = append(issues, ValidationIssue{Path: childPath, Message: message, Severity: SeverityError})
+			}
+		case map[string]interface{}:
+			issues = append(issues, v.validate(additional, child, childPath)...)
+		}
+	}
+	return issues
+}
+
+func (v schemaValidator) countMatches(schemas []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		if subSchema, ok := sub.(map[string]interface{}); ok && len(v.validate(subSchema, value, path)) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// resolve follows local references such as "#/definitions/port".
+func (v schemaValidator) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference %s", ref)
+	}
+	var current interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+		current = m[part]
+	}
+	resolved, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+	}
+	return resolved, nil
+}
+
+func schemaTypes(value interface{}) []string {
+	switch t := value.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesType(value interface{}, types []string) bool {
+	actual := jsonTypeName(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if FormatValue(item) == FormatValue(value) && jsonTypeName(item) == jsonTypeName(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+  "definitions": {
+    "port": {"type": "integer", "minimum": 1, "maximum": 65535}
+  },
+  "type": "object",
+  "required": ["httpHostname", "database"],
+  "properties": {
+    "httpHostname": {"type": "string", "pattern": "^https?://"},
+    "replicas": {"type": "integer", "minimum": 1},
+    "logLevel": {"enum": ["debug", "info", "error"]},
+    "mode": {"const": "production"},
+    "port": {"$ref": "#/definitions/port"},
+    "tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string", "minLength": 2}},
+    "ratio": {"type": "number", "exclusiveMaximum": 1},
+    "timeout": {"anyOf": [{"type": "integer"}, {"type": "string", "pattern": "^[0-9]+s$"}]},
+    "size": {"oneOf": [{"type": "integer"}, {"type": "number"}]},
+    "database": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["password"],
+      "properties": {
+        "host": {"type": "string"},
+        "password": {"type": "string", "minLength": 8}
+      }
+    },
+    "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+  }
+}`
+
+func testChartSpec(t *testing.T) *ChartSpec {
+	t.Helper()
+	spec := &ChartSpec{Defaults: map[string]interface{}{
+		"httpHostname": "https://netsocs.local",
+		"replicas":     1,
+		"database":     map[string]interface{}{"host": "db", "password": "changeme"},
+		"labels":       map[string]interface{}{},
+		"ingress":      map[string]interface{}{"enabled": true},
+	}}
+	if err := json.Unmarshal([]byte(testSchema), &spec.Schema); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func issueMessages(issues []ValidationIssue) map[string]string {
+	messages := map[string]string{}
+	for _, issue := range issues {
+		messages[issue.Path] = issue.Severity + ": " + issue.Message
+	}
+	return messages
+}
+
+func TestValidateValues(t *testing.T) {
+	useTestSettings(t)
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		// want maps paths to a fragment of the expected message; an empty
+		// map expects no issue.
+		want map[string]string
+	}{
+		{"defaults only", map[string]interface{}{}, map[string]string{}},
+		{"valid values", map[string]interface{}{
+			"replicas": 3, "logLevel": "info", "mode": "production", "port": 443,
+			"tags": []interface{}{"ab"}, "ratio": 0.5, "timeout": "30s", "size": 3.5,
+			"labels": map[string]interface{}{"team": "ops"},
+		}, map[string]string{}},
+		{"type", map[string]interface{}{"replicas": "three"}, map[string]string{"replicas": "error: expected integer, got string"}},
+		{"integer", map[string]interface{}{"replicas": 1.5}, map[string]string{"replicas": "expected integer, got number"}},
+		{"minimum", map[string]interface{}{"replicas": 0}, map[string]string{"replicas": "must be >= 1"}},
+		{"pattern", map[string]interface{}{"httpHostname": "netsocs.local"}, map[string]string{"httpHostname": "must match pattern"}},
+		{"enum", map[string]interface{}{"logLevel": "trace"}, map[string]string{"logLevel": "must be one of"}},
+		{"const", map[string]interface{}{"mode": "dev"}, map[string]string{"mode": "must be production"}},
+		{"ref", map[string]interface{}{"port": 70000}, map[string]string{"port": "must be <= 65535"}},
+		{"items", map[string]interface{}{"tags": []interface{}{"a", "bc", "de"}}, map[string]string{
+			"tags":    "must have at most 2 items",
+			"tags[0]": "must be at least 2 characters",
+		}},
+		{"exclusive", map[string]interface{}{"ratio": 1}, map[string]string{"ratio": "must be < 1"}},
+		{"anyOf", map[string]interface{}{"timeout": "soon"}, map[string]string{"timeout": "does not match any"}},
+		{"oneOf", map[string]interface{}{"size": 3}, map[string]string{"size": "exactly one"}},
+		{"required nested", map[string]interface{}{"database": nil}, map[string]string{"database": "required key is missing"}},
+		{"additional properties", map[string]interface{}{"database": map[string]interface{}{"hots": "db"}}, map[string]string{
+			"database.hots": "not allowed by the chart schema (did you mean 'host'?)",
+		}},
+		{"additional schema", map[string]interface{}{"labels": map[string]interface{}{"n": 1}}, map[string]string{"labels.n": "expected string"}},
+		{"unknown key", map[string]interface{}{"ingres": map[string]interface{}{}}, map[string]string{
+			"ingres": "warning: unknown key, not present in the chart defaults (did you mean 'ingress'?)",
+		}},
+		{"unknown nested key", map[string]interface{}{"ingress": map[string]interface{}{"enabeld": true}}, map[string]string{
+			"ingress.enabeld": "did you mean 'enabled'?",
+		}},
+		{"escaped key", map[string]interface{}{"labels": map[string]interface{}{"netsocs.com/tier": 1}}, map[string]string{
+			`labels.netsocs\.com/tier`: "expected string",
+		}},
+	}
+	spec := testChartSpec(t)
+	for _, tt := range tests {
+		got := issueMessages(ValidateValues(tt.values, spec))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: issues = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for path, fragment := range tt.want {
+			if !strings.Contains(got[path], fragment) {
+				t.Errorf("%s: issue at %q = %q, want it to contain %q", tt.name, path, got[path], fragment)
+			}
+		}
+	}
+}
+
+func TestValidateValuesWithoutSchema(t *testing.T) {
+	useTestSettings(t)
+	spec := &ChartSpec{Defaults: map[string]interface{}{"podAnnotations": map[string]interface{}{}}}
+	values := map[string]interface{}{
+		"podAnnotations": map[string]interface{}{"free": "form"},
+		"extra":          true,
+	}
+	got := issueMessages(ValidateValues(values, spec))
+	if len(got) != 1 || !strings.HasPrefix(got["extra"], SeverityWarning) {
+		t.Errorf("issues = %v, want a warning for extra only", got)
+	}
+}
+
+func TestValidateValuesEncrypted(t *testing.T) {
+	useTestSettings(t)
+	spec := testChartSpec(t)
+
+	short, err := EncryptSecret("short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := EncryptSecret("long enough")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With the key, the plaintext is validated, not the ciphertext.
+	got := issueMessages(ValidateValues(map[string]interface{}{"database": map[string]interface{}{"password": short}}, spec))
+	if !strings.Contains(got["database.password"], "at least 8 characters") {
+		t.Errorf("short secret: issues = %v", got)
+	}
+	if got := ValidateValues(map[string]interface{}{"database": map[string]interface{}{"password": long}}, spec); len(got) != 0 {
+		t.Errorf("long secret: issues = %v", got)
+	}
+
+	// Without the key, the secret is skipped instead of checking the
+	// ciphertext against the pattern.
+	if err := os.Remove(CurrentSettings().SecretKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := ValidateValues(map[string]interface{}{"httpHostname": short}, spec); len(got) != 0 {
+		t.Errorf("unreadable secret: issues = %v", got)
+	}
+}
+
+func TestFetchChartSpecCached(t *testing.T) {
+	useTestSettings(t)
+	dir := chartSpecCacheDir("1.2.3")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "values.schema.json"), []byte(testSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("replicas: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cached version is read without running helm.
+	t.Setenv("PATH", "")
+	spec, err := FetchChartSpec("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Version != "1.2.3" || spec.Defaults["replicas"] != 2 || spec.Schema["type"] != "object" {
+		t.Errorf("spec = %+v", spec)
+	}
+	if _, err := FetchChartSpec("9.9.9"); err == nil {
+		t.Error("expected an error for a version that is neither cached nor downloadable")
+	}
+}
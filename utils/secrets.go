@@ -0,0 +1,420 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sensitive values are stored in the values files as
+//
+//	ENC[AES256_GCM,kid:<key id>,iv:<base64>,data:<base64>]
+//
+// encrypted with a key kept outside of the files (Settings.SecretKeyFile).
+// They are only decrypted in memory and handed to Helm through stdin, so
+// neither the values files nor their history hold the plaintext.
+
+const encryptedPrefix = "ENC[AES256_GCM,"
+
+var encryptedRegex = regexp.MustCompile(`^ENC\[AES256_GCM,kid:([0-9a-f]+),iv:([A-Za-z0-9+/=]+),data:([A-Za-z0-9+/=]+)\]$`)
+
+type secretKey struct {
+	id  string
+	key []byte
+}
+
+// SecretEntry is an encrypted value found in a values layer.
+type SecretEntry struct {
+	Path  string `json:"path"`
+	Layer string `json:"layer"`
+	KeyID string `json:"keyId"`
+	// Readable is false when no available key decrypts the value.
+	Readable bool `json:"readable"`
+}
+
+// IsEncrypted reports whether value is an encrypted secret.
+func IsEncrypted(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(s, encryptedPrefix)
+}
+
+// EncryptSecret encrypts plaintext with the current key, creating the key on
+// first use.
+func EncryptSecret(plaintext string) (string, error) {
+	key, err := ensureSecretKey()
+	if err != nil {
+		return "", err
+	}
+	return encryptWith(key, plaintext)
+}
+
+// DecryptSecret decrypts a value produced by EncryptSecret with the current
+// key or a retired one.
+func DecryptSecret(value string) (string, error) {
+	keys, err := loadSecretKeys()
+	if err != nil {
+		return "", err
+	}
+	return decryptWith(keys, value)
+}
+
+// DecryptValues returns a copy of values with every secret decrypted, and
+// the paths of the secrets.
+func DecryptValues(values map[string]interface{}) (map[string]interface{}, []string, error) {
+	flat := FlattenValues(values)
+	var paths []string
+	for _, path := range SortedKeys(flat) {
+		if IsEncrypted(flat[path]) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return values, nil, nil
+	}
+
+	keys, err := loadSecretKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	decrypted := copyValues(values).(map[string]interface{})
+	for _, path := range paths {
+		plaintext, err := decryptWith(keys, flat[path].(string))
+		if err != nil {
+			return nil, nil, fmt.Errorf("secret %s: %w", path, err)
+		}
+		if err := SetValue(decrypted, path, plaintext); err != nil {
+			return nil, nil, err
+		}
+	}
+	return decrypted, paths, nil
+}
+
+// secretOverlay returns YAML with the decrypted secrets of the merged layers,
+// to be passed to Helm after the layer files. Lists holding a secret are
+// included whole because Helm replaces lists instead of merging them. It
+// returns nil when there are no secrets.
+func secretOverlay() ([]byte, error) {
+	merged, err := LoadLayeredValues(nil)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, paths, err := DecryptValues(merged)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	overlay := map[string]interface{}{}
+	for _, path := range paths {
+		segments, err := ParseValuePath(path)
+		if err != nil {
+			return nil, err
+		}
+		for i, segment := range segments {
+			if segment.isIndex {
+				path = joinSegments(segments[:i])
+				break
+			}
+		}
+		value, _, _ := GetValue(decrypted, path)
+		if err := SetValue(overlay, path, value); err != nil {
+			return nil, err
+		}
+	}
+	return yaml.Marshal(overlay)
+}
+
+// ListSecrets returns the encrypted values of every layer without
+// decrypting them beyond checking that a key is available.
+func ListSecrets() ([]SecretEntry, error) {
+	layers, err := ValuesLayers()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := loadSecretKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []SecretEntry
+	for _, layer := range layers {
+		values, err := loadLayer(layer)
+		if err != nil {
+			return nil, err
+		}
+		flat := FlattenValues(values)
+		for _, path := range SortedKeys(flat) {
+			if !IsEncrypted(flat[path]) {
+				continue
+			}
+			entry := SecretEntry{Path: path, Layer: layer.Name}
+			if match := encryptedRegex.FindStringSubmatch(flat[path].(string)); match != nil {
+				entry.KeyID = match[1]
+			}
+			_, err := decryptWith(keys, flat[path].(string))
+			entry.Readable = err == nil
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// RotateSecretKey generates a new key and re-encrypts every secret of every
+// layer with it. The previous key is kept as "<key file>.<key id>" so older
+// history snapshots can still be decrypted. It returns the number of
+// re-encrypted values.
+func RotateSecretKey() (int, error) {
+	keys, err := loadSecretKeys()
+	if err != nil {
+		return 0, err
+	}
+	keyPath := CurrentSettings().SecretKeyFile
+	current := keys[""]
+
+	newKey, err := generateSecretKey()
+	if err != nil {
+		return 0, err
+	}
+	// The new key is saved first so a failure half way through leaves
+	// every file readable.
+	pendingPath := keyPath + ".new"
+	if err := writeSecretKey(pendingPath, newKey); err != nil {
+		return 0, err
+	}
+
+	layers, err := ValuesLayers()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, layer := range layers {
+		original, err := os.ReadFile(layer.Path)
+		if err != nil {
+			return count, fmt.Errorf("error reading %s: %w", layer.Path, err)
+		}
+		doc, err := parseValuesDocument(original)
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", layer.Path, err)
+		}
+
+		changed := 0
+		var walkErr error
+		walkScalars(doc, func(node *yaml.Node) {
+			if walkErr != nil || !strings.HasPrefix(node.Value, encryptedPrefix) {
+				return
+			}
+			plaintext, err := decryptWith(keys, node.Value)
+			if err != nil {
+				walkErr = fmt.Errorf("%s: %w", layer.Path, err)
+				return
+			}
+			if node.Value, err = encryptWith(newKey, plaintext); err != nil {
+				walkErr = err
+			}
+			changed++
+		})
+		if walkErr != nil {
+			return count, walkErr
+		}
+		if changed == 0 {
+			continue
+		}
+
+		content, err := EncodeValuesDocument(doc, original)
+		if err != nil {
+			return count, err
+		}
+		if layer.Name == LayerServer {
+			err = WriteValuesFile(content)
+		} else {
+			err = writeFileAtomic(layer.Path, content, 0600)
+		}
+		if err != nil {
+			return count, err
+		}
+		count += changed
+	}
+
+	if current != nil {
+		if err := os.Rename(keyPath, keyPath+"."+current.id); err != nil {
+			return count, fmt.Errorf("error retiring the previous key: %w", err)
+		}
+	}
+	if err := os.Rename(pendingPath, keyPath); err != nil {
+		return count, fmt.Errorf("error installing the new key: %w", err)
+	}
+	return count, nil
+}
+
+func walkScalars(node *yaml.Node, fn func(*yaml.Node)) {
+	if node.Kind == yaml.ScalarNode {
+		fn(node)
+		return
+	}
+	for _, child := range node.Content {
+		walkScalars(child, fn)
+	}
+}
+
+func encryptWith(key *secretKey, plaintext string) (string, error) {
+	gcm, err := newGCM(key.key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nil, iv, []byte(plaintext), nil)
+	return fmt.Sprintf("%skid:%s,iv:%s,data:%s]", encryptedPrefix, key.id,
+		base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// decryptWith decrypts value with the key it names. keys is indexed by key
+// id, plus "" for the current key.
+func decryptWith(keys map[string]*secretKey, value string) (string, error) {
+	match := encryptedRegex.FindStringSubmatch(value)
+	if match == nil {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	key := keys[match[1]]
+	if key == nil {
+		return "", fmt.Errorf("key %s is not available in %s", match[1], filepath.Dir(CurrentSettings().SecretKeyFile))
+	}
+	iv, err := base64.StdEncoding.DecodeString(match[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(match[3])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	gcm, err := newGCM(key.key)
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != gcm.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value: bad IV length")
+	}
+	plaintext, err := gcm.Open(nil, iv, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("decryption with key %s failed, the value was altered", key.id)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadSecretKeys reads the current key and the retired or pending ones next
+// to it. A missing key file is not an error: the keyring is just empty.
+func loadSecretKeys() (map[string]*secretKey, error) {
+	keyPath := CurrentSettings().SecretKeyFile
+	keys := map[string]*secretKey{}
+
+	current, err := readSecretKey(keyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if current != nil {
+		keys[""] = current
+		keys[current.id] = current
+	}
+
+	others, _ := filepath.Glob(keyPath + ".*")
+	for _, path := range others {
+		key, err := readSecretKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := keys[key.id]; !exists {
+			keys[key.id] = key
+		}
+	}
+	return keys, nil
+}
+
+func ensureSecretKey() (*secretKey, error) {
+	keyPath := CurrentSettings().SecretKeyFile
+	key, err := readSecretKey(keyPath)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if key, err = generateSecretKey(); err != nil {
+		return nil, err
+	}
+	if err := writeSecretKey(keyPath, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func readSecretKey(path string) (*secretKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content)))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("%s is not a valid secret key", path)
+	}
+	return newSecretKey(raw), nil
+}
+
+func generateSecretKey() (*secretKey, error) {
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return nil, fmt.Errorf("error generating secret key: %w", err)
+	}
+	return newSecretKey(raw), nil
+}
+
+func newSecretKey(raw []byte) *secretKey {
+	sum := sha256.Sum256(raw)
+	return &secretKey{id: hex.EncodeToString(sum[:4]), key: raw}
+}
+
+func writeSecretKey(path string, key *secretKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(base64.StdEncoding.EncodeToString(key.key)+"\n"), 0600)
+}
+
+func copyValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			result[key] = copyValues(child)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			result[i] = copyValues(child)
+		}
+		return result
+	default:
+		return v
+	}
+}
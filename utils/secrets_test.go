@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretEnvelope(t *testing.T) {
+	useTestSettings(t)
+	encrypted, err := EncryptSecret("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || !encryptedRegex.MatchString(encrypted) || strings.Contains(encrypted, "hunter2") {
+		t.Fatalf("unexpected envelope %q", encrypted)
+	}
+	again, _ := EncryptSecret("hunter2")
+	if again == encrypted {
+		t.Error("two encryptions of the same value are identical; the IV is not random")
+	}
+	if plaintext, err := DecryptSecret(encrypted); err != nil || plaintext != "hunter2" {
+		t.Errorf("DecryptSecret = %q, %v", plaintext, err)
+	}
+
+	// Flipping a bit of the data must be detected by GCM.
+	match := encryptedRegex.FindStringSubmatch(encrypted)
+	data, _ := base64.StdEncoding.DecodeString(match[3])
+	data[0] ^= 1
+	tampered := strings.Replace(encrypted, match[3], base64.StdEncoding.EncodeToString(data), 1)
+	if _, err := DecryptSecret(tampered); err == nil || !strings.Contains(err.Error(), "altered") {
+		t.Errorf("tampered value: %v", err)
+	}
+
+	for _, malformed := range []string{
+		"ENC[AES256_GCM,kid:zz,iv:AAAA,data:AAAA]",
+		"ENC[AES256_GCM,kid:" + match[1] + ",iv:AAAA,data:AAAA]",
+		encryptedPrefix + "garbage]",
+	} {
+		if _, err := DecryptSecret(malformed); err == nil {
+			t.Errorf("DecryptSecret(%q) succeeded", malformed)
+		}
+	}
+
+	other := strings.Replace(encrypted, "kid:"+match[1], "kid:00000000", 1)
+	if _, err := DecryptSecret(other); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("unknown key id: %v", err)
+	}
+
+	for value, want := range map[interface{}]bool{encrypted: true, "plain": false, 42: false} {
+		if IsEncrypted(value) != want {
+			t.Errorf("IsEncrypted(%v) = %v", value, !want)
+		}
+	}
+}
+
+func TestRotateSecretKey(t *testing.T) {
+	s := useTestSettings(t)
+	encrypted, err := EncryptSecret("rotate-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.ValuesFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.ValuesFile, []byte("# comment\ndatabase:\n  password: "+encrypted+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oldID := encryptedRegex.FindStringSubmatch(encrypted)[1]
+
+	count, err := RotateSecretKey()
+	if err != nil || count != 1 {
+		t.Fatalf("RotateSecretKey = %d, %v", count, err)
+	}
+	values, err := LoadValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, _, _ := GetValue(values, "database.password")
+	if rotated == encrypted || encryptedRegex.FindStringSubmatch(rotated.(string))[1] == oldID {
+		t.Errorf("value was not re-encrypted: %v", rotated)
+	}
+	if plaintext, err := DecryptSecret(rotated.(string)); err != nil || plaintext != "rotate-me" {
+		t.Errorf("DecryptSecret(rotated) = %q, %v", plaintext, err)
+	}
+	// The retired key still decrypts older values, e.g. in history.
+	if plaintext, err := DecryptSecret(encrypted); err != nil || plaintext != "rotate-me" {
+		t.Errorf("DecryptSecret(old) = %q, %v", plaintext, err)
+	}
+	if _, err := os.Stat(s.SecretKeyFile + "." + oldID); err != nil {
+		t.Errorf("retired key not kept: %v", err)
+	}
+}
+
+func TestHistoryOmitsArguments(t *testing.T) {
+	s := useTestSettings(t)
+	if err := os.MkdirAll(filepath.Dir(s.ValuesFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { invokedCommand = nil })
+	SetInvokedCommand([]string{"config", "secret", "set"}, []string{"apply"})
+
+	if err := WriteValuesFile([]byte("database:\n  host: db\n")); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := ListValuesSnapshots()
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("snapshots = %v, %v", snapshots, err)
+	}
+	want := filepath.Base(os.Args[0]) + " config secret set --apply"
+	if snapshots[0].Command != want {
+		t.Errorf("Command = %q, want %q", snapshots[0].Command, want)
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NormalizeVersion strips a leading "v" and surrounding whitespace so that
+// tags like "v1.2.3" and chart versions like "1.2.3" can be compared.
+func NormalizeVersion(version string) string {
+	return strings.TrimPrefix(strings.TrimSpace(version), "v")
+}
+
+// CompareVersions compares two semantic versions and returns -1, 0 or 1.
+// Missing components count as zero and a pre-release sorts before the
+// corresponding release, so "1.2.0-rc1" < "1.2.0".
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitPreRelease(NormalizeVersion(a))
+	bCore, bPre := splitPreRelease(NormalizeVersion(b))
+
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			if aNum < bNum {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return comparePreRelease(aPre, bPre)
+	}
+}
+
+// comparePreRelease compares dot-separated pre-release identifiers as
+// semver does: numbers numerically and below names, so "rc.2" < "rc.10".
+func comparePreRelease(a, b string) int {
+	aIDs := strings.Split(a, ".")
+	bIDs := strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		aNum, aErr := strconv.Atoi(aIDs[i])
+		bNum, bErr := strconv.Atoi(bIDs[i])
+		switch {
+		case aErr == nil && bErr == nil && aNum != bNum:
+			if aNum < bNum {
+				return -1
+			}
+			return 1
+		case aErr == nil && bErr != nil:
+			return -1
+		case aErr != nil && bErr == nil:
+			return 1
+		case aIDs[i] != bIDs[i]:
+			return strings.Compare(aIDs[i], bIDs[i])
+		}
+	}
+	switch {
+	case len(aIDs) < len(bIDs):
+		return -1
+	case len(aIDs) > len(bIDs):
+		return 1
+	}
+	return 0
+}
+
+func splitPreRelease(version string) (string, string) {
+	if idx := strings.IndexByte(version, '+'); idx >= 0 {
+		version = version[:idx]
+	}
+	if idx := strings.IndexByte(version, '-'); idx >= 0 {
+		return version[:idx], version[idx+1:]
+	}
+	return version, ""
+}
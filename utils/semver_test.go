@@ -0,0 +1,60 @@
+package utils
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{" 1.2.3\n", "v1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.9.9", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2.0-rc1", "1.2.0", -1},
+		{"1.2.0", "1.2.0-rc1", 1},
+		{"1.2.0-alpha", "1.2.0-beta", -1},
+		{"1.2.0-rc.2", "1.2.0-rc.10", -1},
+		{"1.2.0-rc.1", "1.2.0-rc", 1},
+		{"1.2.0-1", "1.2.0-alpha", -1},
+		{"1.2.0+build.5", "1.2.0+build.7", 0},
+		{"1.2.0-rc1+build", "1.2.0-rc1", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIsSecurityRelease(t *testing.T) {
+	tests := map[string]bool{
+		"[SECURITY] Fix token leak":       true,
+		"This is a security release":      true,
+		"Improve security of the install": false,
+		"":                                false,
+	}
+	for text, want := range tests {
+		if got := isSecurityRelease(text); got != want {
+			t.Errorf("isSecurityRelease(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestSortReleases(t *testing.T) {
+	releases := []CLIRelease{{Version: "v1.2.0"}, {Version: "v1.10.0"}, {Version: "v1.10.0-rc1"}, {Version: "1.9.0"}}
+	sortReleases(releases)
+	var got []string
+	for _, release := range releases {
+		got = append(got, release.Version)
+	}
+	want := []string{"v1.10.0", "v1.10.0-rc1", "1.9.0", "v1.2.0"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sortReleases = %v, want %v", got, want)
+		}
+	}
+}
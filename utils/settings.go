@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultChart       = "netsocs/netsocs-helm-chart"
+	DefaultReleaseName = "netsocs"
+)
+
+// Settings holds the paths and cluster identities used by every Helm,
+// kubectl and file operation. Values come, from lowest to highest priority,
+// from the defaults, the CLI config file (~/.config/netsocs/cli.yaml), the
+// NETSOCS_* environment variables and the global command line flags.
+type Settings struct {
+	// Home is the directory with values.yaml, the other layers, history and
+	// downloads. Defaults to ~/netsocs.
+	Home string `yaml:"home,omitempty"`
+	// ValuesFile is the per-server values file. Defaults to <home>/values.yaml.
+	ValuesFile  string `yaml:"valuesFile,omitempty"`
+	ReleaseName string `yaml:"releaseName,omitempty"`
+	// Namespace and KubeContext are passed to Helm and kubectl when set;
+	// otherwise the current kubeconfig context and namespace are used.
+	Namespace   string `yaml:"namespace,omitempty"`
+	KubeContext string `yaml:"kubeContext,omitempty"`
+	// Chart is the chart reference as "<repo>/<chart>".
+	Chart string `yaml:"chart,omitempty"`
+	// SecretKeyFile is the key of the encrypted values. It is kept out of
+	// the home directory on purpose. Defaults to ~/.config/netsocs/secret.key.
+	SecretKeyFile string `yaml:"secretKeyFile,omitempty"`
+	// Connectivity configures the checks of "enviroment".
+	Connectivity ConnectivitySettings `yaml:"connectivity,omitempty"`
+	// Proxy is exported to the environment of the CLI and the tools it runs.
+	Proxy ProxySettings `yaml:"proxy,omitempty"`
+}
+
+var settings *Settings
+
+// settingsEnv maps each setting to its environment variable.
+var settingsEnv = []struct {
+	name  string
+	field func(*Settings) *string
+}{
+	{"NETSOCS_HOME", func(s *Settings) *string { return &s.Home }},
+	{"NETSOCS_VALUES", func(s *Settings) *string { return &s.ValuesFile }},
+	{"NETSOCS_RELEASE", func(s *Settings) *string { return &s.ReleaseName }},
+	{"NETSOCS_NAMESPACE", func(s *Settings) *string { return &s.Namespace }},
+	{"NETSOCS_KUBE_CONTEXT", func(s *Settings) *string { return &s.KubeContext }},
+	{"NETSOCS_CHART", func(s *Settings) *string { return &s.Chart }},
+	{"NETSOCS_SECRET_KEY_FILE", func(s *Settings) *string { return &s.SecretKeyFile }},
+}
+
+// SettingsFilePath returns the CLI config file, which can be moved with
+// NETSOCS_CONFIG.
+func SettingsFilePath() (string, error) {
+	if path := os.Getenv("NETSOCS_CONFIG"); path != "" {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "netsocs", "cli.yaml"), nil
+}
+
+// LoadSettings builds the settings from the config file, the environment and
+// flags, the non-empty fields of which take precedence. The result becomes
+// the one returned by CurrentSettings.
+func LoadSettings(flags Settings) (*Settings, error) {
+	s := &Settings{}
+
+	path, err := SettingsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(content, s); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	for _, env := range settingsEnv {
+		if value := os.Getenv(env.name); value != "" {
+			*env.field(s) = value
+		}
+	}
+	for _, env := range settingsEnv {
+		if value := *env.field(&flags); value != "" {
+			*env.field(s) = value
+		}
+	}
+
+	if err := s.complete(); err != nil {
+		return nil, err
+	}
+	s.Proxy.exportProxyEnv()
+	settings = s
+	return s, nil
+}
+
+// CurrentSettings returns the loaded settings, loading them from the config
+// file and environment on first use.
+func CurrentSettings() *Settings {
+	if settings == nil {
+		s, err := LoadSettings(Settings{})
+		if err != nil {
+			// Fall back to the defaults; the error is reported again by
+			// the root command when the flags are parsed.
+			s = &Settings{}
+			s.complete()
+		}
+		settings = s
+	}
+	return settings
+}
+
+// complete fills the defaults and expands "~" in paths.
+func (s *Settings) complete() error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("could not get home directory: %w", err)
+	}
+	expand := func(path string) string {
+		if path == "~" {
+			return homeDir
+		}
+		if strings.HasPrefix(path, "~/") {
+			return filepath.Join(homeDir, path[2:])
+		}
+		return path
+	}
+
+	s.Home = expand(s.Home)
+	if s.Home == "" {
+		s.Home = filepath.Join(homeDir, "netsocs")
+	}
+	s.ValuesFile = expand(s.ValuesFile)
+	if s.ValuesFile == "" {
+		s.ValuesFile = filepath.Join(s.Home, "values.yaml")
+	}
+	if s.ReleaseName == "" {
+		s.ReleaseName = DefaultReleaseName
+	}
+	s.Chart = strings.TrimSpace(s.Chart)
+	if s.Chart == "" {
+		s.Chart = DefaultChart
+	}
+	if !strings.Contains(s.Chart, "/") {
+		return fmt.Errorf("chart %q must be a reference like <repo>/<chart>", s.Chart)
+	}
+	s.SecretKeyFile = expand(s.SecretKeyFile)
+	if s.SecretKeyFile == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return fmt.Errorf("could not get config directory: %w", err)
+		}
+		s.SecretKeyFile = filepath.Join(configDir, "netsocs", "secret.key")
+	}
+	return nil
+}
+
+// RepoName is the Helm repository part of the chart reference.
+func (s *Settings) RepoName() string {
+	return s.Chart[:strings.Index(s.Chart, "/")]
+}
+
+// ChartName is the chart part of the chart reference.
+func (s *Settings) ChartName() string {
+	return s.Chart[strings.LastIndex(s.Chart, "/")+1:]
+}
+
+// HelmCommand returns a helm command with the namespace and kube context
+// flags added.
+func HelmCommand(args ...string) *exec.Cmd {
+	s := CurrentSettings()
+	if s.Namespace != "" {
+		args = append(args, "--namespace", s.Namespace)
+	}
+	if s.KubeContext != "" {
+		args = append(args, "--kube-context", s.KubeContext)
+	}
+	return exec.Command("helm", args...)
+}
+
+// KubectlCommand returns a kubectl command with the namespace and context
+// flags added.
+func KubectlCommand(args ...string) *exec.Cmd {
+	s := CurrentSettings()
+	if s.Namespace != "" {
+		args = append([]string{"--namespace", s.Namespace}, args...)
+	}
+	if s.KubeContext != "" {
+		args = append([]string{"--context", s.KubeContext}, args...)
+	}
+	return exec.Command("kubectl", args...)
+}
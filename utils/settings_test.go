@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestSettings points the settings at an empty config file and a home
+// directory under t.TempDir, and restores them at the end of the test.
+func useTestSettings(t *testing.T) *Settings {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("NETSOCS_CONFIG", filepath.Join(dir, "cli.yaml"))
+	for _, env := range settingsEnv {
+		t.Setenv(env.name, "")
+	}
+	t.Setenv("NETSOCS_HOME", filepath.Join(dir, "netsocs"))
+	t.Setenv("NETSOCS_SECRET_KEY_FILE", filepath.Join(dir, "secret.key"))
+	previous := settings
+	t.Cleanup(func() { settings = previous })
+	s, err := LoadSettings(Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLoadSettingsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "cli.yaml")
+	if err := os.WriteFile(config, []byte("releaseName: from-file\nnamespace: from-file\nchart: repo/from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETSOCS_CONFIG", config)
+	for _, env := range settingsEnv {
+		t.Setenv(env.name, "")
+	}
+	t.Setenv("NETSOCS_HOME", filepath.Join(dir, "home"))
+	t.Setenv("NETSOCS_NAMESPACE", "from-env")
+	previous := settings
+	t.Cleanup(func() { settings = previous })
+
+	s, err := LoadSettings(Settings{Chart: "other/from-flag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ReleaseName != "from-file" || s.Namespace != "from-env" || s.Chart != "other/from-flag" {
+		t.Errorf("got release %q, namespace %q, chart %q", s.ReleaseName, s.Namespace, s.Chart)
+	}
+	if s.ValuesFile != filepath.Join(dir, "home", "values.yaml") {
+		t.Errorf("ValuesFile = %q", s.ValuesFile)
+	}
+	if s.RepoName() != "other" || s.ChartName() != "from-flag" {
+		t.Errorf("RepoName = %q, ChartName = %q", s.RepoName(), s.ChartName())
+	}
+
+	if _, err := LoadSettings(Settings{Chart: "no-repo"}); err == nil {
+		t.Error("expected an error for a chart without repository")
+	}
+}
+
+func TestHelmAndKubectlCommands(t *testing.T) {
+	s := useTestSettings(t)
+	s.Namespace, s.KubeContext = "netsocs", "kind-netsocs"
+
+	helm := HelmCommand("list", "--output", "json")
+	if got, want := strings.Join(helm.Args, " "), "helm list --output json --namespace netsocs --kube-context kind-netsocs"; got != want {
+		t.Errorf("HelmCommand args = %q, want %q", got, want)
+	}
+	kubectl := KubectlCommand("version", "--output", "json")
+	if got, want := strings.Join(kubectl.Args, " "), "kubectl --context kind-netsocs --namespace netsocs version --output json"; got != want {
+		t.Errorf("KubectlCommand args = %q, want %q", got, want)
+	}
+}
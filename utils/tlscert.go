@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// TLSSecretValuePath is the chart value naming the Kubernetes TLS secret
+	// used by the ingress.
+	TLSSecretValuePath   = "tls.secretName"
+	defaultTLSSecret     = "netsocs-tls"
+	certSourceAnnotation = "netsocs.com/cert-source"
+	// lastAppliedAnnotation is where client-side kubectl apply keeps a copy
+	// of the applied object.
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+// Where the certificate of the TLS secret comes from.
+const (
+	CertSourceLocalCA  = "local-ca"
+	CertSourceImported = "imported"
+	CertSourceACME     = "acme"
+)
+
+// ErrTLSSecretNotFound is returned when the TLS secret does not exist yet.
+var ErrTLSSecretNotFound = errors.New("TLS secret not found")
+
+// TLSSecret is the certificate currently stored in the TLS secret.
+type TLSSecret struct {
+	Name  string
+	Chain []*x509.Certificate
+	// Source is one of the CertSource constants, or empty when the secret
+	// was not created by the CLI.
+	Source string
+}
+
+// Managed reports whether the certificate was issued by the local CA and can
+// be regenerated without losing anything.
+func (s *TLSSecret) Managed() bool {
+	return s.Source == CertSourceLocalCA
+}
+
+// TLSSecretName returns the name of the TLS secret from the values, or the
+// chart default.
+func TLSSecretName() string {
+	if values, err := LoadLayeredValues(nil); err == nil {
+		if name, ok, _ := GetValue(values, TLSSecretValuePath); ok {
+			if s, ok := name.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return defaultTLSSecret
+}
+
+// GetTLSSecret reads and parses the TLS secret of the release.
+func GetTLSSecret() (*TLSSecret, error) {
+	name := TLSSecretName()
+	output, err := KubectlCommand("get", "secret", name, "--output", "json").Output()
+	if err != nil {
+		if strings.Contains(commandError(err), "NotFound") {
+			return nil, ErrTLSSecretNotFound
+		}
+		return nil, fmt.Errorf("error reading secret %s: %s", name, commandError(err))
+	}
+
+	var secret struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(output, &secret); err != nil {
+		return nil, fmt.Errorf("error decoding secret %s: %w", name, err)
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(secret.Data["tls.crt"])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding tls.crt of %s: %w", name, err)
+	}
+	chain, err := ParsePEMCertificates(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+	return &TLSSecret{
+		Name:   name,
+		Chain:  chain,
+		Source: secret.Metadata.Annotations[certSourceAnnotation],
+	}, nil
+}
+
+// ParsePEMCertificates returns the certificates of a PEM bundle in order.
+func ParsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
+// CertificateCovers reports whether cert is valid for host, an IP address or
+// DNS name.
+func CertificateCovers(cert *x509.Certificate, host string) bool {
+	return cert.VerifyHostname(host) == nil
+}
+
+// ApplyTLSSecret creates or replaces the TLS secret, recording where the
+// certificate comes from.
+func ApplyTLSSecret(name string, certPEM, keyPEM []byte, source string) error {
+	metadata := map[string]interface{}{
+		"name":        name,
+		"annotations": map[string]string{certSourceAnnotation: source},
+	}
+	if ns := CurrentSettings().Namespace; ns != "" {
+		metadata["namespace"] = ns
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "kubernetes.io/tls",
+		"metadata":   metadata,
+		"data": map[string]string{
+			"tls.crt": base64.StdEncoding.EncodeToString(certPEM),
+			"tls.key": base64.StdEncoding.EncodeToString(keyPEM),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A client-side apply would copy the private key into the
+	// last-applied-configuration annotation; server-side apply does not.
+	cmd := KubectlCommand("apply", "--server-side", "--force-conflicts", "--field-manager", "netsocs-cli", "--filename", "-")
+	cmd.Stdin = bytes.NewReader(manifest)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error applying secret %s: %s", name, strings.TrimSpace(string(output)))
+	}
+	// Drop the copy left by client-side applies of earlier versions.
+	cmd = KubectlCommand("annotate", "secret", name, lastAppliedAnnotation+"-")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error cleaning secret %s: %s", name, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ServedCertificate returns the chain presented at address, host[:port]
+// with port 443 by default, without verifying it.
+func ServedCertificate(address string) ([]*x509.Certificate, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = hostOnly(address), "443"
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServedCertificateUsesPort(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	address := strings.TrimPrefix(server.URL, "https://")
+	chain, err := ServedCertificate(address)
+	if err != nil {
+		t.Fatalf("ServedCertificate(%s): %v", address, err)
+	}
+	if !chain[0].Equal(server.Certificate()) {
+		t.Errorf("got the certificate of %s", chain[0].Subject)
+	}
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	updateCheckInterval = 24 * time.Hour
+	updateCheckGrace    = 2 * time.Second
+	securityAnnotation  = "netsocs.com/security-release"
+)
+
+// UpdateCheckResult is the outcome of the last update check, cached on disk
+// so the network is queried at most once per day.
+type UpdateCheckResult struct {
+	CheckedAt   time.Time `json:"checkedAt"`
+	CLILatest   string    `json:"cliLatest,omitempty"`
+	CLISecurity bool      `json:"cliSecurity,omitempty"`
+	// ChartDeployed is the chart version deployed at the time of the check;
+	// upgrade and rollback update it through RecordDeployedChartVersion.
+	ChartDeployed string `json:"chartDeployed,omitempty"`
+	ChartLatest   string `json:"chartLatest,omitempty"`
+	ChartSecurity bool   `json:"chartSecurity,omitempty"`
+}
+
+// UpdateNotifier runs the update check in the background while a command is
+// executing and prints a notice once the command has finished.
+type UpdateNotifier struct {
+	currentCLI string
+	previous   *UpdateCheckResult
+	// done is nil when the cached result was fresh and nothing runs in the
+	// background.
+	done chan struct{}
+}
+
+// UpdateChecksDisabled reports whether the user opted out of update checks
+// through NETSOCS_NO_UPDATE_CHECK.
+func UpdateChecksDisabled() bool {
+	return EnvBool("NETSOCS_NO_UPDATE_CHECK")
+}
+
+// StartUpdateCheck loads the cached result and, when it is older than a day,
+// refreshes it in the background. A fresh cache costs no network request and
+// no Helm call.
+func StartUpdateCheck(currentCLI string) *UpdateNotifier {
+	n := &UpdateNotifier{
+		currentCLI: currentCLI,
+		previous:   loadUpdateCheck(),
+	}
+	if n.previous != nil && time.Since(n.previous.CheckedAt) <= updateCheckInterval {
+		return n
+	}
+
+	n.done = make(chan struct{})
+	go func() {
+		defer close(n.done)
+		result, err := refreshUpdateCheck()
+		if err != nil {
+			// Offline or blocked: keep the previous result but do not retry
+			// before a day has passed, so every command is not slowed down.
+			pterm.Debug.Printfln("Update check failed: %v", err)
+			result = &UpdateCheckResult{}
+			if n.previous != nil {
+				*result = *n.previous
+			}
+			result.CheckedAt = time.Now()
+		}
+		if err := saveUpdateCheck(result); err != nil {
+			pterm.Debug.Printfln("Could not cache update check: %v", err)
+		}
+	}()
+	return n
+}
+
+// PrintNotice prints a short notice to stderr when newer versions exist. It
+// waits briefly for a background refresh; a refresh still running then is
+// abandoned without touching the cache, so the next command tries again.
+func (n *UpdateNotifier) PrintNotice() {
+	if n.done != nil {
+		select {
+		case <-n.done:
+		case <-time.After(updateCheckGrace):
+			return
+		}
+	}
+
+	// The cache is read again since the refresh or the command itself, e.g.
+	// an upgrade, may have changed it.
+	result := loadUpdateCheck()
+	if result == nil {
+		return
+	}
+
+	var lines []string
+	if result.CLILatest != "" && CompareVersions(result.CLILatest, n.currentCLI) > 0 {
+		lines = append(lines, updateLine("CLI", n.currentCLI, result.CLILatest, result.CLISecurity, "netsocs cli update"))
+	}
+	if result.ChartLatest != "" && result.ChartDeployed != "" &&
+		CompareVersions(result.ChartLatest, result.ChartDeployed) > 0 {
+		lines = append(lines, updateLine("NETSOCS", result.ChartDeployed, result.ChartLatest, result.ChartSecurity, "netsocs upgrade"))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	for _, line := range lines {
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// RecordDeployedChartVersion updates the deployed chart version of the
+// cached update check after an upgrade or rollback.
+func RecordDeployedChartVersion() {
+	result := loadUpdateCheck()
+	if result == nil {
+		return
+	}
+	result.ChartDeployed = DeployedChartVersion()
+	if err := saveUpdateCheck(result); err != nil {
+		pterm.Debug.Printfln("Could not cache update check: %v", err)
+	}
+}
+
+func updateLine(component, current, latest string, security bool, command string) string {
+	line := fmt.Sprintf("A new %s version is available: %s -> %s (run '%s')", component, current, latest, command)
+	if security {
+		return pterm.Red("[security] " + line)
+	}
+	return pterm.Yellow(line)
+}
+
+// refreshUpdateCheck queries the latest CLI and chart releases. It fails
+// only when neither could be fetched.
+func refreshUpdateCheck() (*UpdateCheckResult, error) {
+	result := &UpdateCheckResult{CheckedAt: time.Now()}
+
+	source, cliErr := NewReleaseSource("")
+	if cliErr == nil {
+		var release CLIRelease
+		if release, cliErr = FindCLIRelease(source, ""); cliErr == nil {
+			result.CLILatest = release.Version
+			result.CLISecurity = release.Security
+		}
+	}
+
+	result.ChartDeployed = DeployedChartVersion()
+	entries, chartErr := FetchChartIndex(ChartRepoURL())
+	if chartErr == nil {
+		if latest, ok := LatestChartRelease(entries); ok {
+			result.ChartLatest = latest.Version
+			result.ChartSecurity = latest.Annotations[securityAnnotation] == "true"
+		}
+	}
+
+	if cliErr != nil && chartErr != nil {
+		return nil, fmt.Errorf("CLI releases: %v; chart index: %v", cliErr, chartErr)
+	}
+	return result, nil
+}
+
+// chartAnnotations reads the annotations of a chart version from Chart.yaml.
+func chartAnnotations(version string) (map[string]string, error) {
+	args := []string{"show", "chart", CurrentSettings().Chart}
+	if version != "" {
+		args = append(args, "--version", version)
+	}
+	output, err := HelmCommand(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading chart metadata: %w", err)
+	}
+	var chart struct {
+		Annotations map[string]string `yaml:"annotations"`
+	}
+	if err := yaml.Unmarshal(output, &chart); err != nil {
+		return nil, fmt.Errorf("error decoding chart metadata: %w", err)
+	}
+	return chart.Annotations, nil
+}
+
+func updateCheckCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "netsocs", "update-check.json"), nil
+}
+
+func loadUpdateCheck() *UpdateCheckResult {
+	path, err := updateCheckCachePath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var result UpdateCheckResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return &result
+}
+
+func saveUpdateCheck(result *UpdateCheckResult) error {
+	path, err := updateCheckCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
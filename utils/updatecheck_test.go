@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrintNoticeKeepsCacheOnTimeout(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	checked := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	previous := &UpdateCheckResult{CheckedAt: checked, CLILatest: "1.0.0"}
+	if err := saveUpdateCheck(previous); err != nil {
+		t.Fatal(err)
+	}
+
+	// A refresh that never finishes, as on a slow link.
+	n := &UpdateNotifier{currentCLI: "1.0.0", previous: previous, done: make(chan struct{})}
+	n.PrintNotice()
+
+	cached := loadUpdateCheck()
+	if cached == nil || !cached.CheckedAt.Equal(checked) {
+		t.Errorf("cache was re-stamped after a timeout: %+v", cached)
+	}
+}
@@ -0,0 +1,456 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pathSegment is one step of a dotted values path: a map key or a list index.
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// ParseValuePath splits a path like "ingress.hosts[0].name" into segments.
+// A backslash escapes the next character, as in Helm's --set, so keys with
+// dots or brackets can be addressed: "podAnnotations.netsocs\.com/tier".
+func ParseValuePath(path string) ([]pathSegment, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	invalid := fmt.Errorf("invalid path %q", path)
+
+	var segments []pathSegment
+	var key strings.Builder
+	hasKey, emptyPart, afterIndex := false, true, false
+	flushKey := func() {
+		if hasKey {
+			segments = append(segments, pathSegment{key: key.String()})
+			key.Reset()
+			hasKey = false
+		}
+	}
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '.':
+			if emptyPart {
+				return nil, invalid
+			}
+			flushKey()
+			emptyPart, afterIndex = true, false
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			digits := path[i+1 : i+end]
+			n, err := strconv.Atoi(digits)
+			if err != nil || n < 0 || strings.TrimLeft(digits, "0123456789") != "" {
+				return nil, fmt.Errorf("invalid index [%s] in path %q", digits, path)
+			}
+			flushKey()
+			segments = append(segments, pathSegment{index: n, isIndex: true})
+			emptyPart, afterIndex = false, true
+			i += end
+		case ']':
+			return nil, invalid
+		default:
+			if afterIndex {
+				return nil, invalid
+			}
+			if c == '\\' {
+				if i+1 == len(path) {
+					return nil, invalid
+				}
+				i++
+				c = path[i]
+			}
+			key.WriteByte(c)
+			hasKey, emptyPart = true, false
+		}
+	}
+	if emptyPart {
+		return nil, invalid
+	}
+	flushKey()
+	return segments, nil
+}
+
+// EscapePathKey escapes the characters of a map key that ParseValuePath
+// would otherwise read as separators.
+func EscapePathKey(key string) string {
+	if !strings.ContainsAny(key, `\.[]`) {
+		return key
+	}
+	var b strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`\.[]`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// joinPath returns the path of key below prefix.
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return EscapePathKey(key)
+	}
+	return prefix + "." + EscapePathKey(key)
+}
+
+// checkListIndex rejects indexes past the end of a list: a value can replace
+// an item or be appended, but lists are not padded with nulls.
+func checkListIndex(index, length int) error {
+	if index > length {
+		return fmt.Errorf("index [%d] is out of range: the list has %d items, use [%d] to append", index, length, length)
+	}
+	return nil
+}
+
+// ParseTypedValue converts a command line value to the requested type:
+// string, int, bool, list (comma separated), json or auto (YAML rules).
+func ParseTypedValue(raw, valueType string) (interface{}, error) {
+	switch valueType {
+	case "", "string":
+		return raw, nil
+	case "int":
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return value, nil
+	case "bool":
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return value, nil
+	case "list":
+		list := []interface{}{}
+		if raw == "" {
+			return list, nil
+		}
+		for _, item := range strings.Split(raw, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list, nil
+	case "json":
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return value, nil
+	case "auto":
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+			return raw, nil
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q (use string, int, bool, list, json or auto)", valueType)
+	}
+}
+
+// ValuesFilePath returns the path of the values.yaml managed by the CLI.
+func ValuesFilePath() (string, error) {
+	return CurrentSettings().ValuesFile, nil
+}
+
+// LoadValues reads and decodes values.yaml.
+func LoadValues() (map[string]interface{}, error) {
+	valuesPath, err := ValuesFilePath()
+	if err != nil {
+		return nil, err
+	}
+	yamlFile, err := os.ReadFile(valuesPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", valuesPath, err)
+	}
+	data := map[string]interface{}{}
+	if err := yaml.Unmarshal(yamlFile, &data); err != nil {
+		return nil, fmt.Errorf("error decoding YAML: %w", err)
+	}
+	return data, nil
+}
+
+// ChartDefaultValues returns the default values of the NETSOCS chart.
+func ChartDefaultValues() (map[string]interface{}, error) {
+	output, err := HelmCommand("show", "values", CurrentSettings().Chart).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error getting default Helm values: %s", commandError(err))
+	}
+	data := map[string]interface{}{}
+	if err := yaml.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("error decoding chart defaults: %w", err)
+	}
+	return data, nil
+}
+
+// GetValue returns the value at path and whether it exists.
+func GetValue(data map[string]interface{}, path string) (interface{}, bool, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	var current interface{} = data
+	for _, segment := range segments {
+		if segment.isIndex {
+			list, ok := current.([]interface{})
+			if !ok || segment.index >= len(list) {
+				return nil, false, nil
+			}
+			current = list[segment.index]
+			continue
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		if current, ok = m[segment.key]; !ok {
+			return nil, false, nil
+		}
+	}
+	return current, true, nil
+}
+
+// SetValue sets the value at path, creating intermediate maps as needed. An
+// index equal to the length of a list appends to it.
+func SetValue(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return err
+	}
+	if segments[0].isIndex {
+		return fmt.Errorf("path %q must start with a key", path)
+	}
+	_, err = setSegment(data, segments, value)
+	return err
+}
+
+func setSegment(container interface{}, segments []pathSegment, value interface{}) (interface{}, error) {
+	segment := segments[0]
+	if segment.isIndex {
+		list, _ := container.([]interface{})
+		if err := checkListIndex(segment.index, len(list)); err != nil {
+			return nil, err
+		}
+		if segment.index == len(list) {
+			list = append(list, nil)
+		}
+		if len(segments) == 1 {
+			list[segment.index] = value
+			return list, nil
+		}
+		child, err := setSegment(list[segment.index], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[segment.index] = child
+		return list, nil
+	}
+
+	m, ok := container.(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+	}
+	if len(segments) == 1 {
+		m[segment.key] = value
+		return m, nil
+	}
+	child, err := setSegment(m[segment.key], segments[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	m[segment.key] = child
+	return m, nil
+}
+
+// UnsetValue removes the value at path. It returns false when the path did
+// not exist.
+func UnsetValue(data map[string]interface{}, path string) (bool, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return false, err
+	}
+	parentPath := segments[:len(segments)-1]
+	last := segments[len(segments)-1]
+
+	var parent interface{} = data
+	for _, segment := range parentPath {
+		if segment.isIndex {
+			list, ok := parent.([]interface{})
+			if !ok || segment.index >= len(list) {
+				return false, nil
+			}
+			parent = list[segment.index]
+			continue
+		}
+		m, ok := parent.(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		if parent, ok = m[segment.key]; !ok {
+			return false, nil
+		}
+	}
+
+	if last.isIndex {
+		list, ok := parent.([]interface{})
+		if !ok || last.index >= len(list) {
+			return false, nil
+		}
+		// Lists are reached through their parent, so rebuild it in place.
+		updated := append(list[:last.index:last.index], list[last.index+1:]...)
+		return true, SetValue(data, joinSegments(parentPath), updated)
+	}
+	m, ok := parent.(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	if _, exists := m[last.key]; !exists {
+		return false, nil
+	}
+	delete(m, last.key)
+	return true, nil
+}
+
+func joinSegments(segments []pathSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if segment.isIndex {
+			fmt.Fprintf(&b, "[%d]", segment.index)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(EscapePathKey(segment.key))
+	}
+	return b.String()
+}
+
+// FlattenValues returns every leaf of data keyed by its dotted path.
+// Empty maps and lists are kept as leaves.
+func FlattenValues(data map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	flattenInto(result, "", data)
+	return result
+}
+
+func flattenInto(result map[string]interface{}, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			result[prefix] = v
+			return
+		}
+		for key, child := range v {
+			flattenInto(result, joinPath(prefix, key), child)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			result[prefix] = v
+			return
+		}
+		for i, child := range v {
+			flattenInto(result, fmt.Sprintf("%s[%d]", prefix, i), child)
+		}
+	default:
+		result[prefix] = v
+	}
+}
+
+// SortedKeys returns the keys of a flattened values map in order.
+func SortedKeys(flat map[string]interface{}) []string {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MergeValues merges override into base the way Helm does: maps are merged
+// recursively, any other value replaces the base and null removes the key.
+func MergeValues(base, override map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(base))
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range override {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		baseMap, baseIsMap := result[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			result[key] = MergeValues(baseMap, overrideMap)
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// FormatValue renders a value on a single line for tables and messages.
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ValueChange is a difference between two sets of values for one key.
+type ValueChange struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"` // added, removed or changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DiffValues compares two sets of values key by key. "added" means the key
+// only exists in newValues.
+func DiffValues(oldValues, newValues map[string]interface{}) []ValueChange {
+	oldFlat := FlattenValues(oldValues)
+	newFlat := FlattenValues(newValues)
+
+	var changes []ValueChange
+	for _, key := range SortedKeys(oldFlat) {
+		newValue, exists := newFlat[key]
+		switch {
+		case !exists:
+			changes = append(changes, ValueChange{Path: key, Kind: "removed", Old: oldFlat[key]})
+		case FormatValue(newValue) != FormatValue(oldFlat[key]):
+			changes = append(changes, ValueChange{Path: key, Kind: "changed", Old: oldFlat[key], New: newValue})
+		}
+	}
+	for _, key := range SortedKeys(newFlat) {
+		if _, exists := oldFlat[key]; !exists {
+			changes = append(changes, ValueChange{Path: key, Kind: "added", New: newFlat[key]})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
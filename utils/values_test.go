@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValuePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []pathSegment
+	}{
+		{"image.tag", []pathSegment{{key: "image"}, {key: "tag"}}},
+		{"ingress.hosts[0].host", []pathSegment{{key: "ingress"}, {key: "hosts"}, {index: 0, isIndex: true}, {key: "host"}}},
+		{"matrix[1][2]", []pathSegment{{key: "matrix"}, {index: 1, isIndex: true}, {index: 2, isIndex: true}}},
+		{`podAnnotations.netsocs\.com/tier`, []pathSegment{{key: "podAnnotations"}, {key: "netsocs.com/tier"}}},
+		{`weird\[0\]\\key`, []pathSegment{{key: `weird[0]\key`}}},
+		{"[0].name", []pathSegment{{index: 0, isIndex: true}, {key: "name"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseValuePath(tt.path)
+		if err != nil {
+			t.Errorf("ParseValuePath(%q): %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseValuePath(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"", " ", "a..b", ".a", "a.", "a[", "a[x]", "a[-1]", "a]", "a[0]b", `a\`, "a[99999999999999999999]"} {
+		if _, err := ParseValuePath(path); err == nil {
+			t.Errorf("ParseValuePath(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestEscapePathKeyRoundTrip(t *testing.T) {
+	for _, key := range []string{"plain", "netsocs.com/tier", "a[0]", `back\slash`, "."} {
+		segments, err := ParseValuePath("root." + EscapePathKey(key))
+		if err != nil || len(segments) != 2 || segments[1].key != key {
+			t.Errorf("key %q: got %+v, %v", key, segments, err)
+		}
+	}
+}
+
+func TestSetGetUnsetValue(t *testing.T) {
+	values := map[string]interface{}{
+		"list": []interface{}{"a", "b"},
+	}
+	if err := SetValue(values, "image.tag", "1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetValue(values, "list[2]", "c"); err != nil {
+		t.Fatalf("appending: %v", err)
+	}
+	if err := SetValue(values, "list[1]", "B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetValue(values, `annotations.netsocs\.com/tier`, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetValue(values, "list[9999999999]", "x"); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("SetValue past the end: %v, want out of range", err)
+	}
+	if err := SetValue(values, "new[1]", "x"); err == nil {
+		t.Error("SetValue on index 1 of a missing list succeeded")
+	}
+	if err := SetValue(values, "[0]", "x"); err == nil {
+		t.Error("SetValue on a path starting with an index succeeded")
+	}
+
+	want := map[string]interface{}{
+		"image":       map[string]interface{}{"tag": "1.2.3"},
+		"list":        []interface{}{"a", "B", "c"},
+		"annotations": map[string]interface{}{"netsocs.com/tier": "web"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("values = %#v, want %#v", values, want)
+	}
+
+	if value, ok, _ := GetValue(values, `annotations.netsocs\.com/tier`); !ok || value != "web" {
+		t.Errorf("GetValue escaped key = %v, %v", value, ok)
+	}
+	if _, ok, _ := GetValue(values, "list[3]"); ok {
+		t.Error("GetValue past the end of a list found a value")
+	}
+
+	if removed, err := UnsetValue(values, "list[0]"); !removed || err != nil {
+		t.Errorf("UnsetValue(list[0]) = %v, %v", removed, err)
+	}
+	if removed, _ := UnsetValue(values, "image.missing"); removed {
+		t.Error("UnsetValue of a missing key reported a removal")
+	}
+	if got := values["list"]; !reflect.DeepEqual(got, []interface{}{"B", "c"}) {
+		t.Errorf("list after unset = %v", got)
+	}
+}
+
+func TestFlattenValues(t *testing.T) {
+	values := map[string]interface{}{
+		"a":           map[string]interface{}{"b": 1, "empty": map[string]interface{}{}},
+		"list":        []interface{}{map[string]interface{}{"x": true}, "y"},
+		"none":        []interface{}{},
+		"annotations": map[string]interface{}{"netsocs.com/tier": "web"},
+	}
+	want := map[string]interface{}{
+		"a.b":                           1,
+		"a.empty":                       map[string]interface{}{},
+		"list[0].x":                     true,
+		"list[1]":                       "y",
+		"none":                          []interface{}{},
+		`annotations.netsocs\.com/tier`: "web",
+	}
+	got := FlattenValues(values)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FlattenValues = %#v, want %#v", got, want)
+	}
+	for path, value := range got {
+		if found, ok, err := GetValue(values, path); err != nil || !ok || !reflect.DeepEqual(found, value) {
+			t.Errorf("GetValue(%q) = %v, %v, %v", path, found, ok, err)
+		}
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]interface{}{
+		"image":  map[string]interface{}{"repository": "netsocs", "tag": "1.0"},
+		"list":   []interface{}{1, 2},
+		"remove": "me",
+	}
+	override := map[string]interface{}{
+		"image":  map[string]interface{}{"tag": "2.0"},
+		"list":   []interface{}{3},
+		"remove": nil,
+	}
+	want := map[string]interface{}{
+		"image": map[string]interface{}{"repository": "netsocs", "tag": "2.0"},
+		"list":  []interface{}{3},
+	}
+	if got := MergeValues(base, override); !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeValues = %#v, want %#v", got, want)
+	}
+	if base["remove"] != "me" {
+		t.Error("MergeValues modified its base")
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	oldValues := map[string]interface{}{"a": 1, "b": "x", "c": true}
+	newValues := map[string]interface{}{"a": 1, "b": "y", "d": "new"}
+	got := DiffValues(oldValues, newValues)
+	want := []ValueChange{
+		{Path: "b", Kind: "changed", Old: "x", New: "y"},
+		{Path: "c", Kind: "removed", Old: true},
+		{Path: "d", Kind: "added", New: "new"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffValues = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseTypedValue(t *testing.T) {
+	tests := []struct {
+		raw, valueType string
+		want           interface{}
+		wantErr        bool
+	}{
+		{"42", "string", "42", false},
+		{"42", "int", 42, false},
+		{"x", "int", nil, true},
+		{"true", "bool", true, false},
+		{"maybe", "bool", nil, true},
+		{"a, b,c", "list", []interface{}{"a", "b", "c"}, false},
+		{"", "list", []interface{}{}, false},
+		{`{"a":[1]}`, "json", map[string]interface{}{"a": []interface{}{float64(1)}}, false},
+		{"{", "json", nil, true},
+		{"3", "auto", 3, false},
+		{"yes", "auto", "yes", false},
+		{"x", "float", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTypedValue(tt.raw, tt.valueType)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTypedValue(%q, %q) = %#v, %v", tt.raw, tt.valueType, got, err)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Edits to values.yaml go through the yaml.Node tree instead of a map so that
+// comments, key order, anchors and quoting written by "helm show values"
+// survive, and only the touched keys change.
+
+// LoadValuesDocument parses values.yaml into a document node.
+func LoadValuesDocument() (*yaml.Node, error) {
+	valuesPath, err := ValuesFilePath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(valuesPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", valuesPath, err)
+	}
+	return parseValuesDocument(content)
+}
+
+func parseValuesDocument(content []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("error decoding YAML: %w", err)
+	}
+	if doc.Kind == 0 {
+		// Empty file: start a document with an empty mapping.
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("values.yaml must contain a mapping at the top level")
+	}
+	return &doc, nil
+}
+
+// EncodeValuesDocument renders the document keeping the indentation width
+// and the blank lines between keys found in the original content.
+func EncodeValuesDocument(doc *yaml.Node, original []byte) ([]byte, error) {
+	spaced := map[string]bool{}
+	if originalDoc, err := parseValuesDocument(original); err == nil {
+		lines := strings.Split(string(original), "\n")
+		walkKeys(originalDoc.Content[0], "", func(path string, key *yaml.Node) {
+			if start := keyStartLine(key); start >= 2 && strings.TrimSpace(lines[start-2]) == "" {
+				spaced[path] = true
+			}
+		})
+	}
+
+	encoded, err := encodeNode(doc, detectIndent(original))
+	if err != nil || len(spaced) == 0 {
+		return encoded, err
+	}
+
+	// yaml.v3 drops blank lines, so put them back before the same keys.
+	encodedDoc, err := parseValuesDocument(encoded)
+	if err != nil {
+		return encoded, nil
+	}
+	insertBefore := map[int]bool{}
+	walkKeys(encodedDoc.Content[0], "", func(path string, key *yaml.Node) {
+		if spaced[path] {
+			insertBefore[keyStartLine(key)] = true
+		}
+	})
+	var out []string
+	for i, line := range strings.Split(string(encoded), "\n") {
+		if insertBefore[i+1] && len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		out = append(out, line)
+	}
+	return []byte(strings.Join(out, "\n")), nil
+}
+
+// walkKeys calls fn for every mapping key below node with its dotted path.
+func walkKeys(node *yaml.Node, prefix string, fn func(path string, key *yaml.Node)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			path := joinPath(prefix, node.Content[i].Value)
+			fn(path, node.Content[i])
+			walkKeys(node.Content[i+1], path, fn)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			walkKeys(item, fmt.Sprintf("%s[%d]", prefix, i), fn)
+		}
+	}
+}
+
+// keyStartLine is the line where a key starts, including its head comment.
+func keyStartLine(key *yaml.Node) int {
+	if key.HeadComment == "" {
+		return key.Line
+	}
+	return key.Line - strings.Count(key.HeadComment, "\n") - 1
+}
+
+func encodeNode(doc *yaml.Node, indent int) ([]byte, error) {
+	plainMergeKeys(doc)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("error generating YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("error generating YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// plainMergeKeys clears the !!merge tag of "<<" keys, which yaml.v3 would
+// otherwise write out explicitly as "!!merge <<".
+func plainMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" && node.Value == "<<" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		plainMergeKeys(child)
+	}
+}
+
+// detectIndent returns the indentation width of the first nested line, 2 by
+// default.
+func detectIndent(content []byte) int {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == line {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent >= 2 && indent <= 8 {
+			return indent
+		}
+	}
+	return 2
+}
+
+// SetNodeValue sets the value at path in the document, creating missing
+// mappings. An index equal to the length of a list appends to it. Comments
+// attached to an existing value are kept.
+func SetNodeValue(doc *yaml.Node, path string, value interface{}) error {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return err
+	}
+	if segments[0].isIndex {
+		return fmt.Errorf("path %q must start with a key", path)
+	}
+
+	var valueNode yaml.Node
+	if err := valueNode.Encode(value); err != nil {
+		return fmt.Errorf("error encoding value: %w", err)
+	}
+
+	current := doc.Content[0]
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		if current.Kind == yaml.AliasNode {
+			return fmt.Errorf("path %q goes through an alias, edit the anchored value instead", path)
+		}
+
+		if segment.isIndex {
+			if current.Kind != yaml.SequenceNode {
+				resetNode(current, yaml.SequenceNode, "!!seq")
+			}
+			if err := checkListIndex(segment.index, len(current.Content)); err != nil {
+				return fmt.Errorf("path %q: %w", path, err)
+			}
+			if segment.index == len(current.Content) {
+				current.Content = append(current.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"})
+			}
+			if last {
+				return replaceNodeChecked(doc, path, current.Content[segment.index], &valueNode)
+			}
+			current = current.Content[segment.index]
+			continue
+		}
+
+		if current.Kind != yaml.MappingNode {
+			resetNode(current, yaml.MappingNode, "!!map")
+		}
+		child := mappingValue(current, segment.key)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			current.Content = append(current.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: segment.key},
+				child)
+		}
+		if last {
+			return replaceNodeChecked(doc, path, child, &valueNode)
+		}
+		current = child
+	}
+	return nil
+}
+
+// UnsetNodeValue removes the value at path from the document, together with
+// the comments attached to it. It returns false when the path did not exist.
+func UnsetNodeValue(doc *yaml.Node, path string) (bool, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	parent := doc.Content[0]
+	for _, segment := range segments[:len(segments)-1] {
+		parent = nodeChild(parent, segment)
+		if parent == nil {
+			return false, nil
+		}
+	}
+
+	last := segments[len(segments)-1]
+	if last.isIndex {
+		if parent.Kind != yaml.SequenceNode || last.index >= len(parent.Content) {
+			return false, nil
+		}
+		if err := checkAnchorsUnused(doc, path, parent.Content[last.index], false); err != nil {
+			return false, err
+		}
+		parent.Content = append(parent.Content[:last.index], parent.Content[last.index+1:]...)
+		return true, nil
+	}
+	if parent.Kind != yaml.MappingNode {
+		return false, nil
+	}
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == last.key {
+			if err := checkAnchorsUnused(doc, path, parent.Content[i+1], false); err != nil {
+				return false, err
+			}
+			parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func nodeChild(node *yaml.Node, segment pathSegment) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if segment.isIndex {
+		if node.Kind != yaml.SequenceNode || segment.index >= len(node.Content) {
+			return nil
+		}
+		return node.Content[segment.index]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	return mappingValue(node, segment.key)
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// replaceNodeChecked replaces target with value unless that would leave an
+// alias of the document without its anchor.
+func replaceNodeChecked(doc *yaml.Node, path string, target, value *yaml.Node) error {
+	if err := checkAnchorsUnused(doc, path, target, true); err != nil {
+		return err
+	}
+	if target.Anchor != "" && value.Kind != target.Kind && len(aliasesOf(doc, target)) > 0 {
+		return fmt.Errorf("path %q is anchored as &%s and used by aliases, so its new value must keep the same type", path, target.Anchor)
+	}
+	replaceNode(target, value)
+	return nil
+}
+
+// checkAnchorsUnused returns an error when node, or a node below it, holds
+// an anchor that an alias outside node refers to. With keepRoot the anchor
+// of node itself is allowed, since replaceNode keeps it.
+func checkAnchorsUnused(doc *yaml.Node, path string, node *yaml.Node, keepRoot bool) error {
+	inside := map[*yaml.Node]bool{}
+	var collect func(n *yaml.Node)
+	collect = func(n *yaml.Node) {
+		inside[n] = true
+		for _, child := range n.Content {
+			collect(child)
+		}
+	}
+	collect(node)
+
+	var err error
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if err != nil || inside[n] {
+			return
+		}
+		if n.Kind == yaml.AliasNode && inside[n.Alias] && !(keepRoot && n.Alias == node) {
+			err = fmt.Errorf("path %q defines the anchor &%s used by *%s elsewhere in values.yaml; edit the keys below it or remove the alias first", path, n.Alias.Anchor, n.Value)
+			return
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return err
+}
+
+// aliasesOf returns the aliases of the document that refer to node.
+func aliasesOf(doc, node *yaml.Node) []*yaml.Node {
+	var aliases []*yaml.Node
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.AliasNode && n.Alias == node {
+			aliases = append(aliases, n)
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return aliases
+}
+
+// replaceNode overwrites target with value while keeping the comments and
+// the anchor of target and, for scalars, its quoting style.
+func replaceNode(target, value *yaml.Node) {
+	head, line, foot := target.HeadComment, target.LineComment, target.FootComment
+	anchor := target.Anchor
+	style := target.Style
+	wasScalar := target.Kind == yaml.ScalarNode
+
+	*target = *value
+	target.HeadComment, target.LineComment, target.FootComment = head, line, foot
+	target.Anchor = anchor
+	if wasScalar && value.Kind == yaml.ScalarNode && value.Tag == "!!str" {
+		target.Style = style
+	}
+}
+
+func resetNode(node *yaml.Node, kind yaml.Kind, tag string) {
+	node.Kind = kind
+	node.Tag = tag
+	node.Value = ""
+	node.Style = 0
+	node.Content = nil
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSetNodeValuePaths(t *testing.T) {
+	original := []byte("list:\n  - a\nannotations: {}\n")
+	doc, err := parseValuesDocument(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetNodeValue(doc, "list[1]", "b"); err != nil {
+		t.Fatalf("appending: %v", err)
+	}
+	if err := SetNodeValue(doc, "list[9999999999]", "x"); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("SetNodeValue past the end: %v, want out of range", err)
+	}
+	if err := SetNodeValue(doc, `annotations.netsocs\.com/tier`, "web"); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := EncodeValuesDocument(doc, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "list:\n  - a\n  - b\nannotations: {netsocs.com/tier: web}\n"
+	if string(encoded) != want {
+		t.Errorf("encoded:\n%s\nwant:\n%s", encoded, want)
+	}
+}
+
+const anchoredValues = `# Shared settings
+base: &base
+  replicas: 1 # one replica
+  image: "netsocs"
+
+web:
+  <<: *base
+  port: 80
+
+worker:
+  <<: *base
+
+# Extra labels
+labels: &labels
+  tier: backend
+podLabels: *labels
+`
+
+func TestEncodeValuesDocumentRoundTrip(t *testing.T) {
+	doc, err := parseValuesDocument([]byte(anchoredValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := EncodeValuesDocument(doc, []byte(anchoredValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != anchoredValues {
+		t.Errorf("unchanged document was rewritten:\n%s", encoded)
+	}
+}
+
+func TestSetNodeValueKeepsAnchors(t *testing.T) {
+	tests := []struct {
+		path  string
+		value interface{}
+		want  string
+	}{
+		{"base.replicas", 2, "  replicas: 2 # one replica\n"},
+		{"base", map[string]interface{}{"replicas": 3, "image": "netsocs"}, "base: &base\n  image: netsocs\n  replicas: 3\n\nweb:\n"},
+		{"web.port", 8080, "web:\n  <<: *base\n  port: 8080\n\nworker:\n"},
+		{"worker.replicas", 4, "worker:\n  <<: *base\n  replicas: 4\n\n# Extra labels\n"},
+	}
+	for _, tt := range tests {
+		original := []byte(anchoredValues)
+		doc, err := parseValuesDocument(original)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := SetNodeValue(doc, tt.path, tt.value); err != nil {
+			t.Errorf("SetNodeValue(%q): %v", tt.path, err)
+			continue
+		}
+		encoded, err := EncodeValuesDocument(doc, original)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(encoded), tt.want) {
+			t.Errorf("SetNodeValue(%q) wrote:\n%s\nwant it to contain:\n%s", tt.path, encoded, tt.want)
+		}
+		if strings.Contains(string(encoded), "!!merge") {
+			t.Errorf("SetNodeValue(%q) tagged the merge keys:\n%s", tt.path, encoded)
+		}
+		// The result must still parse, with the aliases resolved.
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(encoded, &values); err != nil {
+			t.Errorf("SetNodeValue(%q) wrote invalid YAML: %v\n%s", tt.path, err, encoded)
+		}
+	}
+}
+
+func TestAnchoredNodesInUse(t *testing.T) {
+	original := []byte(anchoredValues)
+	doc, err := parseValuesDocument(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetNodeValue(doc, "base", "scalar"); err == nil {
+		t.Error("replacing an anchored mapping used by merge keys with a scalar succeeded")
+	}
+	if _, err := UnsetNodeValue(doc, "labels"); err == nil {
+		t.Error("removing an anchor used by an alias succeeded")
+	}
+	if removed, err := UnsetNodeValue(doc, "labels.tier"); err != nil || !removed {
+		t.Errorf("removing a key below an anchor: %v, %v", removed, err)
+	}
+	if err := SetNodeValue(doc, "podLabels.tier", "x"); err == nil {
+		t.Error("setting a path through an alias succeeded")
+	}
+}